@@ -0,0 +1,119 @@
+package bytes_pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+var (
+	ErrSizeTooLarge = errors.New("请求的大小超出了最大的块大小")
+	ErrPoolClosed   = errors.New("对象池已关闭")
+)
+
+//限制已分配未归还块的总字节数（按块大小计算，跨所有大小类别）
+//超出预算时TryAlloc返回false，AllocContext等待归还；Alloc不受限制，但分配出的块同样计入预算
+func WithMemoryBudget(bytes int64) Option {
+	return func(p *BytesPool) {
+		if bytes > 0 {
+			p.budget = bytes
+		}
+	}
+}
+
+//尝试从池中分配，池耗尽、超出预算或size超出最大块大小时返回false，不会退化为make
+func (p *BytesPool) TryAlloc(size int) ([]byte, bool) {
+	data := p.tryAlloc(size)
+	if data == nil {
+		return nil, false
+	}
+	if p.tracker != nil {
+		p.tracker.alloc(data)
+	}
+	return data[:size], true
+}
+
+//从池中分配，池耗尽或超出预算时阻塞等待其他协程归还，直到ctx结束
+func (p *BytesPool) AllocContext(ctx context.Context, size int) ([]byte, error) {
+	if p.classIndex(size) < 0 {
+		return nil, ErrSizeTooLarge
+	}
+	for {
+		if atomic.LoadInt32(&p.closed) != 0 {
+			return nil, ErrPoolClosed
+		}
+		data := p.tryAlloc(size)
+		if data == nil {
+			var err error
+			data, err = p.wait(ctx, size)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if data != nil {
+			if p.tracker != nil {
+				p.tracker.alloc(data)
+			}
+			return data[:size], nil
+		}
+	}
+}
+
+//已分配未归还块的总字节数，未设置预算时始终为0
+func (p *BytesPool) InUseBytes() int64 {
+	return atomic.LoadInt64(&p.inuseBytes)
+}
+
+func (p *BytesPool) tryAlloc(size int) []byte {
+	if atomic.LoadInt32(&p.closed) != 0 {
+		return nil
+	}
+	i := p.classIndex(size)
+	if i < 0 {
+		return nil
+	}
+	group := p.entityList[i]
+	if p.budget > 0 && atomic.AddInt64(&p.inuseBytes, int64(group.esize)) > p.budget {
+		atomic.AddInt64(&p.inuseBytes, -int64(group.esize))
+		return nil
+	}
+	data := group.pop(p)
+	if data == nil && p.budget > 0 {
+		atomic.AddInt64(&p.inuseBytes, -int64(group.esize))
+	}
+	return data
+}
+
+//登记为等待者后再尝试一次分配，避免错过登记前发生的归还；返回nil表示被唤醒，需要重新分配
+//登记后还要检查是否已关闭：Close在登记前调用notify时没有等待者，不会唤醒本协程
+func (p *BytesPool) wait(ctx context.Context, size int) ([]byte, error) {
+	p.waitMu.Lock()
+	ch := p.waitCh
+	atomic.AddInt32(&p.waiters, 1)
+	p.waitMu.Unlock()
+	defer atomic.AddInt32(&p.waiters, -1)
+
+	if atomic.LoadInt32(&p.closed) != 0 {
+		return nil, ErrPoolClosed
+	}
+	if data := p.tryAlloc(size); data != nil {
+		return data, nil
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-ch:
+		return nil, nil
+	}
+}
+
+//有块归还时唤醒所有等待者
+func (p *BytesPool) notify() {
+	if atomic.LoadInt32(&p.waiters) == 0 {
+		return
+	}
+	p.waitMu.Lock()
+	close(p.waitCh)
+	p.waitCh = make(chan struct{})
+	p.waitMu.Unlock()
+}
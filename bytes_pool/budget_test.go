@@ -0,0 +1,97 @@
+package bytes_pool
+
+import (
+	"context"
+	. "github.com/tevid/gohamcrest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBytesPool_TryAlloc(t *testing.T) {
+	bp := NewBytesPool(128, 256, 512)
+	a, ok := bp.TryAlloc(200)
+	Assert(t, ok, Equal(true))
+	Assert(t, cap(a), Equal(256))
+	b, ok := bp.TryAlloc(200)
+	Assert(t, ok, Equal(true))
+
+	//池已耗尽，不会退化为make
+	_, ok = bp.TryAlloc(200)
+	Assert(t, ok, Equal(false))
+	_, ok = bp.TryAlloc(1024)
+	Assert(t, ok, Equal(false))
+
+	bp.Release(a)
+	bp.Release(b)
+	_, ok = bp.TryAlloc(200)
+	Assert(t, ok, Equal(true))
+}
+
+func TestBytesPool_MemoryBudget(t *testing.T) {
+	bp := NewBytesPool(128, 1024, 4096, WithMemoryBudget(1024))
+	a, ok := bp.TryAlloc(1000)
+	Assert(t, ok, Equal(true))
+	Assert(t, bp.InUseBytes(), Equal(int64(1024)))
+
+	//其他大小类别同样受预算限制
+	_, ok = bp.TryAlloc(100)
+	Assert(t, ok, Equal(false))
+
+	bp.Release(a)
+	Assert(t, bp.InUseBytes(), Equal(int64(0)))
+	b, ok := bp.TryAlloc(100)
+	Assert(t, ok, Equal(true))
+	Assert(t, bp.InUseBytes(), Equal(int64(128)))
+	bp.Release(b)
+}
+
+func TestBytesPool_AllocContext(t *testing.T) {
+	bp := NewBytesPool(128, 128, 256, WithMemoryBudget(256))
+	a, err := bp.AllocContext(context.Background(), 128)
+	Assert(t, err, NilVal())
+	_, err = bp.AllocContext(context.Background(), 128)
+	Assert(t, err, NilVal())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = bp.AllocContext(ctx, 128)
+	Assert(t, err, Equal(context.DeadlineExceeded))
+
+	_, err = bp.AllocContext(context.Background(), 129)
+	Assert(t, err, Equal(ErrSizeTooLarge))
+
+	//归还后唤醒等待者
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		data, err := bp.AllocContext(context.Background(), 100)
+		Assert(t, err, NilVal())
+		Assert(t, len(data), Equal(100))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	bp.Release(a)
+	wg.Wait()
+
+	//关闭后唤醒等待者并返回错误
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		bp.Close()
+	}()
+	_, err = bp.AllocContext(context.Background(), 128)
+	Assert(t, err, Equal(ErrPoolClosed))
+
+	//Close先于登记等待者完成时不会永久阻塞
+	done := make(chan error, 1)
+	go func() {
+		_, err := bp.wait(context.Background(), 128)
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		Assert(t, err, Equal(ErrPoolClosed))
+	case <-time.After(time.Second):
+		t.Fatal("wait在关闭后阻塞")
+	}
+}
@@ -0,0 +1,169 @@
+package bytes_pool
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	MIN_READ_SIZE = 512 //ReadFrom每次读取前至少预留的空间
+)
+
+var ErrNegativeRead = errors.New("reader返回了负数的读取长度")
+
+//基于BytesPool的可增长缓冲区，用法与bytes.Buffer类似
+//容量不足时从池中申请下一个大小类别的块，并归还旧块；使用完毕后需调用Free归还内存
+type Buffer struct {
+	pool *BytesPool
+	buf  []byte //有效数据为buf[off:]
+	off  int    //WriteTo的读取位置
+}
+
+var (
+	_ io.Writer     = (*Buffer)(nil)
+	_ io.ReaderFrom = (*Buffer)(nil)
+	_ io.WriterTo   = (*Buffer)(nil)
+)
+
+//构建初始容量为size的缓冲区
+func NewBuffer(pool *BytesPool, size int) *Buffer {
+	b := &Buffer{pool: pool}
+	if size > 0 {
+		b.buf = pool.Alloc(size)[:0]
+	}
+	return b
+}
+
+//未读取的数据，在下一次修改缓冲区前有效
+func (b *Buffer) Bytes() []byte {
+	return b.buf[b.off:]
+}
+
+func (b *Buffer) String() string {
+	if b == nil {
+		return "<nil>"
+	}
+	return string(b.buf[b.off:])
+}
+
+//未读取的数据长度
+func (b *Buffer) Len() int {
+	return len(b.buf) - b.off
+}
+
+func (b *Buffer) Cap() int {
+	return cap(b.buf)
+}
+
+//保证至少还能写入n个字节
+func (b *Buffer) Grow(n int) {
+	if n < 0 {
+		panic("bytes_pool.Buffer.Grow: negative count")
+	}
+	b.grow(n)
+}
+
+func (b *Buffer) grow(n int) {
+	length := len(b.buf)
+	if length+n <= cap(b.buf) {
+		return
+	}
+	//数据已被读完时，直接从头复用
+	if b.off == length {
+		b.buf = b.buf[:0]
+		b.off = 0
+		if n <= cap(b.buf) {
+			return
+		}
+	}
+	need := len(b.buf) - b.off + n
+	newCap := cap(b.buf) * DEFAULT_INCREATE_FACTOR
+	if newCap < need {
+		newCap = need
+	}
+	data := b.pool.Alloc(newCap)
+	data = data[:copy(data, b.buf[b.off:])]
+	b.release()
+	b.buf = data
+	b.off = 0
+}
+
+//实现io.Writer
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.grow(len(p))
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+func (b *Buffer) WriteString(s string) (int, error) {
+	b.grow(len(s))
+	b.buf = append(b.buf, s...)
+	return len(s), nil
+}
+
+func (b *Buffer) WriteByte(c byte) error {
+	b.grow(1)
+	b.buf = append(b.buf, c)
+	return nil
+}
+
+//实现io.ReaderFrom，读取r直到io.EOF
+func (b *Buffer) ReadFrom(r io.Reader) (int64, error) {
+	var total int64
+	for {
+		b.grow(MIN_READ_SIZE)
+		length := len(b.buf)
+		n, err := r.Read(b.buf[length:cap(b.buf)])
+		if n < 0 {
+			panic(ErrNegativeRead)
+		}
+		b.buf = b.buf[:length+n]
+		total += int64(n)
+		if err == io.EOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+//实现io.WriterTo，写出所有未读取的数据
+func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
+	length := b.Len()
+	if length == 0 {
+		return 0, nil
+	}
+	n, err := w.Write(b.buf[b.off:])
+	if n > length {
+		panic("bytes_pool.Buffer.WriteTo: invalid Write count")
+	}
+	b.off += n
+	if err != nil {
+		return int64(n), err
+	}
+	if n != length {
+		return int64(n), io.ErrShortWrite
+	}
+	b.Reset()
+	return int64(n), nil
+}
+
+//清空数据，保留已申请的块
+func (b *Buffer) Reset() {
+	b.buf = b.buf[:0]
+	b.off = 0
+}
+
+//清空数据并把块归还回池中，之后仍可继续写入
+func (b *Buffer) Free() {
+	b.release()
+	b.buf = nil
+	b.off = 0
+}
+
+func (b *Buffer) release() {
+	if cap(b.buf) > 0 {
+		b.pool.Release(b.buf[:0])
+	}
+}
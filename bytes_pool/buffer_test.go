@@ -0,0 +1,50 @@
+package bytes_pool
+
+import (
+	"bytes"
+	. "github.com/tevid/gohamcrest"
+	"strings"
+	"testing"
+)
+
+func TestBuffer_Write(t *testing.T) {
+	bp := NewBytesPool(32, 1024, 4096)
+	buf := NewBuffer(bp, 10)
+	Assert(t, buf.Cap(), Equal(32))
+
+	buf.WriteString(strings.Repeat("a", 30))
+	buf.Write([]byte("bcd"))
+	Assert(t, buf.Cap(), Equal(64))
+	Assert(t, buf.String(), Equal(strings.Repeat("a", 30)+"bcd"))
+
+	//旧块已归还
+	Assert(t, bp.entityList[0].load()[0].list.freeNum(), Equal(int64(4096/32)))
+
+	buf.Free()
+	Assert(t, buf.Len(), Equal(0))
+	Assert(t, bp.entityList[1].load()[0].list.freeNum(), Equal(int64(4096/64)))
+}
+
+func TestBuffer_ReadFromAndWriteTo(t *testing.T) {
+	bp := NewBytesPool(512, 4096, 16*1024)
+	src := strings.Repeat("0123456789", 300)
+
+	buf := NewBuffer(bp, 0)
+	n, err := buf.ReadFrom(strings.NewReader(src))
+	Assert(t, err, NilVal())
+	Assert(t, n, Equal(int64(len(src))))
+	Assert(t, buf.Cap(), Equal(4096))
+
+	var out bytes.Buffer
+	n, err = buf.WriteTo(&out)
+	Assert(t, err, NilVal())
+	Assert(t, n, Equal(int64(len(src))))
+	Assert(t, out.String(), Equal(src))
+	Assert(t, buf.Len(), Equal(0))
+
+	//超出最大块后退化为普通内存
+	buf.Write([]byte(src))
+	buf.Write([]byte(src))
+	Assert(t, buf.Len(), Equal(2*len(src)))
+	buf.Free()
+}
@@ -1,9 +1,13 @@
 package bytes_pool
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
-	"runtime"
+	"sort"
+	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -13,8 +17,17 @@ import (
 const (
 	DEFAULT_ENTITY_LIST_LEN = 20
 	DEFAULT_INCREATE_FACTOR = 2
+	DEFAULT_MAX_ENTITY_NUM  = 1 //默认每个大小类别只有一个实体，不扩容
 	INIT_POS                = 1
 	POS_SHIFT               = 32
+
+	MIN_SHRINK_INTERVAL = time.Second //空闲回收检查的最小间隔
+)
+
+var (
+	ErrChunkReleased   = errors.New("chunk had been release")
+	ErrChunkNotAligned = errors.New("归还的数据不是从块的起始位置开始")
+	ErrChunksInUse     = errors.New("实体还有未归还的块，没有释放其内存")
 )
 
 //bytes池
@@ -22,142 +35,418 @@ type (
 	BytesPool struct {
 		initEntitySize int
 		maxEntitySize  int
-		entityList     []entity
+		memorySize     int           //每个实体的内存大小
+		mmapFlags      int           //实体内存使用mmap分配时的选项，0表示在堆上分配
+		budget         int64         //已分配未归还块的总字节数上限，0表示不限制
+		inuseBytes     int64         //已分配未归还块的总字节数，只在设置了budget时统计
+		maxEntityNum   int           //每个大小类别最多的实体数量
+		idleTimeout    time.Duration //扩容实体空闲多久后回收，0表示不回收
+		localCacheSize int           //每个P本地缓存的块数量，0表示不使用本地缓存
+		tracker        *leakTracker  //泄漏检测，nil表示未开启
+		classSizes     []int         //指定的大小类别
+		classSteps     int           //每翻倍一次划分的大小类别数
+		entityList     []*entityGroup
+		lookup         classLookup
+
+		closeOnce sync.Once
+		closeCh   chan struct{}
+		closed    int32
+
+		mmapErrMu sync.Mutex
+		mmapErr   error //第一次mmap相关的失败
+
+		waitMu  sync.Mutex
+		waitCh  chan struct{} //有块归还时关闭并重建，唤醒所有等待的AllocContext
+		waiters int32
+	}
+
+	//同一大小类别的实体集合，按需扩容
+	entityGroup struct {
+		esize    int
+		chunkLen int
+		mu       sync.Mutex   //保护扩容与收缩
+		entities atomic.Value //[]*entity，按beginPtr升序排列，写时复制
+		locals   []localCache //按P分片的本地缓存
+		tracker  *leakTracker
 	}
 
 	//池实体
 	entity struct {
-		esize    int     //实体所属大小
-		memory   []byte  //公共内存空间
-		chunks   []chunk //数据块链表
-		beginPtr uintptr //开始指针
-		endPtr   uintptr //结束指针
-		pos      uint64  //当前内存地址位置
+		esize    int       //实体所属大小
+		memory   []byte    //公共内存空间
+		chunks   []chunk   //数据块链表
+		beginPtr uintptr   //开始指针
+		endPtr   uintptr   //结束指针
+		list     *freeList //空闲块链表
+		mapped   bool      //memory是否通过mmap分配
+
+		idleHead  uint64 //上次空闲检查时链表的head
+		idleSince int64  //开始空闲的时间
 	}
 
 	//数据块
 	chunk struct {
-		data    []byte
-		flag    uint64
-		nextPos uint64
+		data []byte
 	}
+
+	//对象池选项
+	Option func(*BytesPool)
 )
 
+//每个大小类别最多扩容到n个实体
+func WithMaxEntityNum(n int) Option {
+	return func(p *BytesPool) {
+		if n > 0 {
+			p.maxEntityNum = n
+		}
+	}
+}
+
+//扩容出来的实体空闲超过d后被回收，每个大小类别至少保留一个实体
+func WithIdleTimeout(d time.Duration) Option {
+	return func(p *BytesPool) {
+		if d > 0 {
+			p.idleTimeout = d
+		}
+	}
+}
+
+//为每个P维护最多n个块的本地缓存，同一P上的分配与归还不必竞争全局链表
+func WithLocalCacheSize(n int) Option {
+	return func(p *BytesPool) {
+		if n > 0 {
+			p.localCacheSize = n
+		}
+	}
+}
+
 //构建对象池
-func NewBytesPool(initSize, maxSize, memorySize int) *BytesPool {
+func NewBytesPool(initSize, maxSize, memorySize int, opts ...Option) *BytesPool {
 	pool := &BytesPool{
 		initEntitySize: initSize,
 		maxEntitySize:  maxSize,
-		entityList:     make([]entity, 0, DEFAULT_ENTITY_LIST_LEN),
+		memorySize:     memorySize,
+		maxEntityNum:   DEFAULT_MAX_ENTITY_NUM,
+		classSteps:     DEFAULT_CLASS_STEPS,
+		entityList:     make([]*entityGroup, 0, DEFAULT_ENTITY_LIST_LEN),
+		closeCh:        make(chan struct{}),
+		waitCh:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(pool)
+	}
+
+	sizes := pool.classSizes
+	if sizes == nil {
+		sizes = geometricSizes(initSize, maxSize, pool.classSteps)
+	}
+	sizes = normalizeSizes(sizes, memorySize)
+	//指定了大小类别时，以类别范围为准
+	if pool.classSizes != nil && len(sizes) > 0 {
+		pool.initEntitySize = sizes[0]
+		pool.maxEntitySize = sizes[len(sizes)-1]
 	}
 
 	//构造若干大小的连续分配区
-	for bytesSize := initSize; bytesSize <= maxSize && bytesSize <= memorySize; bytesSize *= DEFAULT_INCREATE_FACTOR {
-
-		//块长度
-		chunkLen := memorySize / bytesSize
-		//构造bytes实体
-		entity := entity{
-			esize:  bytesSize,
-			memory: make([]byte, memorySize),
-			chunks: make([]chunk, chunkLen),
-			pos:    INIT_POS << POS_SHIFT, //从 INIT_POS 开始
+	for _, bytesSize := range sizes {
+		group := &entityGroup{
+			esize:    bytesSize,
+			chunkLen: memorySize / bytesSize,
+			tracker:  pool.tracker,
 		}
-		for i := 0; i < chunkLen; i++ {
-			posIdx := i + INIT_POS
-			chk := &entity.chunks[i]
-
-			//从entiry的内存区域中连续划分空间指向内部的chunk块
-			chk.data = entity.memory[i*bytesSize : (i+1)*bytesSize : (i+1)*bytesSize]
-
-			//处理最后一个内存块
-			if i < chunkLen-1 {
-				//左移，构成连续的内存块
-				chk.nextPos = uint64(posIdx+1) << POS_SHIFT
-			} else {
-				entity.beginPtr = uintptr(unsafe.Pointer(&entity.memory[0]))
-				entity.endPtr = uintptr(unsafe.Pointer(&chk.data[0]))
-			}
+		group.entities.Store([]*entity{pool.newEntity(bytesSize)})
+		if pool.localCacheSize > 0 {
+			group.locals = newLocalCaches(pool.localCacheSize)
 		}
-		pool.entityList = append(pool.entityList, entity)
+		pool.entityList = append(pool.entityList, group)
+	}
+	pool.lookup = newClassLookup(sizes)
+
+	if pool.idleTimeout > 0 && pool.maxEntityNum > 1 {
+		go pool.shrinkLoop()
 	}
 	return pool
 }
 
+//构造bytes实体
+func (p *BytesPool) newEntity(bytesSize int) *entity {
+	//块长度
+	chunkLen := p.memorySize / bytesSize
+	e := &entity{
+		esize:  bytesSize,
+		chunks: make([]chunk, chunkLen),
+		list:   newFreeList(chunkLen),
+	}
+	if p.mmapFlags != 0 {
+		e.memory, e.mapped = p.allocMemory()
+	} else {
+		e.memory = make([]byte, p.memorySize)
+	}
+	for i := 0; i < chunkLen; i++ {
+		//从entiry的内存区域中连续划分空间指向内部的chunk块
+		e.chunks[i].data = e.memory[i*bytesSize : (i+1)*bytesSize : (i+1)*bytesSize]
+	}
+	e.beginPtr = slicePtr(e.memory)
+	e.endPtr = e.beginPtr + uintptr(chunkLen*bytesSize)
+	return e
+}
+
 //从对象池中分配字节数为size大小的可复用字节数值
 func (p *BytesPool) Alloc(size int) []byte {
-	if size > p.maxEntitySize {
+	if size > p.maxEntitySize || atomic.LoadInt32(&p.closed) != 0 {
 		return make([]byte, size)
 	}
-	//遍历存储实体链表，查找可满足分配的实体
-	for i := 0; i < len(p.entityList); i++ {
-		//满足可分配的条件
-		if size <= p.entityList[i].esize {
-			data := p.entityList[i].pop()
-			if data != nil {
-				return data[:size]
+	//查找可满足分配的最小大小类别
+	if i := p.classIndex(size); i >= 0 {
+		data := p.entityList[i].pop(p)
+		if data != nil {
+			if p.budget > 0 {
+				atomic.AddInt64(&p.inuseBytes, int64(cap(data)))
+			}
+			if p.tracker != nil {
+				p.tracker.alloc(data)
 			}
-			break
+			return data[:size]
 		}
 	}
 	return make([]byte, size)
 }
 
 //把字节数值归还回池中
+//重复归还同一块内存，或归还块的子切片时panic
 func (p *BytesPool) Release(data []byte) {
 	//因为Alloc阶段可能出现data[:size]的情况， 所以这里计算的长度不能使用len
 	size := cap(data)
-	//遍历存储实体链表，查找可满足归还的实体
+	if size == 0 {
+		return
+	}
+	ptr := slicePtr(data)
+	//容量与大小类别一致时，查找所属的实体
+	if i := p.classIndex(size); i >= 0 && p.entityList[i].esize == size {
+		if e := p.entityList[i].find(ptr); e != nil {
+			p.entityList[i].push(e, ptr)
+			if p.budget > 0 {
+				atomic.AddInt64(&p.inuseBytes, -int64(size))
+			}
+			p.notify()
+			return
+		}
+	}
+	//容量与所属实体不符，可能是池外分配的内存，也可能是块的子切片
 	for i := 0; i < len(p.entityList); i++ {
-		//满足可归还的条件
-		if p.entityList[i].esize == size {
-			p.entityList[i].push(data)
-			break
+		if p.entityList[i].find(ptr) != nil {
+			panic(ErrChunkNotAligned)
 		}
 	}
 }
 
-func (e *entity) pop() []byte {
+//清空本地缓存，并立即回收所有完全空闲的扩容实体，每个大小类别至少保留一个实体
+func (p *BytesPool) Shrink() {
+	for _, group := range p.entityList {
+		group.flush()
+		group.shrink(0)
+	}
+}
+
+//停止后台回收，并释放mmap分配的实体内存
+//还有块未归还的实体不会释放内存，已分配的块仍可使用和归还，此时返回ErrChunksInUse
+func (p *BytesPool) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		atomic.StoreInt32(&p.closed, 1)
+		close(p.closeCh)
+		p.notify()
+		inuse := 0
+		for _, group := range p.entityList {
+			group.flush()
+			group.mu.Lock()
+			for _, e := range group.load() {
+				if !e.list.drain() {
+					if e.mapped {
+						inuse++
+					}
+					continue
+				}
+				if freeErr := e.free(); freeErr != nil && err == nil {
+					err = freeErr
+				}
+			}
+			group.mu.Unlock()
+		}
+		if inuse > 0 && err == nil {
+			err = fmt.Errorf("%w: %d个实体", ErrChunksInUse, inuse)
+		}
+	})
+	return err
+}
+
+//定期回收空闲的扩容实体
+func (p *BytesPool) shrinkLoop() {
+	interval := p.idleTimeout / 4
+	if interval < MIN_SHRINK_INTERVAL {
+		interval = MIN_SHRINK_INTERVAL
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
-		currentPos := atomic.LoadUint64(&e.pos)
-		if currentPos == 0 { //超出了实体的存储范围了
-			return nil
+		select {
+		case <-p.closeCh:
+			return
+		case <-ticker.C:
+			for _, group := range p.entityList {
+				group.flush()
+				group.shrink(p.idleTimeout)
+			}
 		}
-		//根据内存空间地址，获取当前内存块
-		posIndex := currentPos>>32 - INIT_POS
-		chk := &e.chunks[posIndex]
-		//下一个连续空间的地址位置
-		nextPos := atomic.LoadUint64(&chk.nextPos)
-		if atomic.CompareAndSwapUint64(&e.pos, currentPos, nextPos) {
-			//移出chk
-			atomic.StoreUint64(&chk.nextPos, 0)
-			return chk.data
+	}
+}
+
+func (g *entityGroup) load() []*entity {
+	return g.entities.Load().([]*entity)
+}
+
+func (g *entityGroup) pop(p *BytesPool) []byte {
+	if data := g.popLocal(); data != nil {
+		return data
+	}
+	for _, e := range g.load() {
+		if data := e.pop(); data != nil {
+			return data
 		}
-		runtime.Gosched()
 	}
+	if data := g.steal(); data != nil {
+		return data
+	}
+	return g.grow(p)
 }
 
-func (e *entity) push(data []byte) {
-	//归还的时候，获取data的指针
-	ptr := (*reflect.SliceHeader)(unsafe.Pointer(&data)).Data
-	//检查指针范围的有效性
-	if e.beginPtr <= ptr && ptr <= e.endPtr {
-		posIndex := (ptr - e.beginPtr) / uintptr(e.esize)
-		chk := e.chunks[posIndex]
-		if chk.nextPos != 0 {
-			panic("chunk had been release")
+func (g *entityGroup) push(e *entity, ptr uintptr) {
+	idx := e.index(ptr)
+	if !e.list.release(idx) {
+		panic(ErrChunkReleased)
+	}
+	if g.tracker != nil {
+		g.tracker.release(e.chunks[idx].data)
+	}
+	if !g.pushLocal(e, idx) {
+		e.list.link(idx)
+	}
+}
+
+//所有实体都已分配完，追加新的实体
+func (g *entityGroup) grow(p *BytesPool) []byte {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	entities := g.load()
+	//加锁期间可能已经有其他协程完成了扩容或归还
+	for _, e := range entities {
+		if data := e.pop(); data != nil {
+			return data
 		}
-		chk.flag++
-		updatePos := uint64(posIndex+1)<<32 + chk.flag
-		for {
-			currentPos := atomic.LoadUint64(&e.pos)
-			//归还的块，指向当前实体分配位置
-			atomic.StoreUint64(&chk.nextPos, currentPos)
-			if atomic.CompareAndSwapUint64(&e.pos, currentPos, updatePos) {
-				break
+	}
+	if len(entities) >= p.maxEntityNum || atomic.LoadInt32(&p.closed) != 0 {
+		return nil
+	}
+	e := p.newEntity(g.esize)
+	data := e.pop()
+	g.store(append(entities[:len(entities):len(entities)], e))
+	return data
+}
+
+//查找指针所在的实体
+func (g *entityGroup) find(ptr uintptr) *entity {
+	entities := g.load()
+	//二分查找data所属的实体
+	idx := sort.Search(len(entities), func(i int) bool {
+		return entities[i].endPtr > ptr
+	})
+	if idx < len(entities) && entities[idx].beginPtr <= ptr {
+		return entities[idx]
+	}
+	return nil
+}
+
+//按beginPtr排序后发布新的实体列表
+func (g *entityGroup) store(entities []*entity) {
+	sort.Slice(entities, func(i, j int) bool {
+		return entities[i].beginPtr < entities[j].beginPtr
+	})
+	g.entities.Store(entities)
+}
+
+//回收空闲时间超过idleTimeout的扩容实体，idleTimeout为0时回收所有完全空闲的扩容实体
+func (g *entityGroup) shrink(idleTimeout time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	entities := g.load()
+	if len(entities) <= 1 {
+		return
+	}
+	now := time.Now().UnixNano()
+	remain := make([]*entity, 0, len(entities))
+	for i, e := range entities {
+		removed := i - len(remain)
+		if len(entities)-removed > 1 && e.isIdle(now, idleTimeout) && e.retire() {
+			if g.tracker != nil {
+				g.tracker.forget(e)
 			}
-			runtime.Gosched()
+			e.free()
+			continue
 		}
+		remain = append(remain, e)
+	}
+	if len(remain) != len(entities) {
+		g.store(remain)
 	}
+}
+
+//实体的所有块都已归还，且链表在idleTimeout内没有发生变化
+func (e *entity) isIdle(now int64, idleTimeout time.Duration) bool {
+	currentHead := atomic.LoadUint64(&e.list.head)
+	if e.list.freeNum() != int64(len(e.chunks)) {
+		return false
+	}
+	if currentHead != e.idleHead {
+		e.idleHead = currentHead
+		e.idleSince = now
+	}
+	return now-e.idleSince >= int64(idleTimeout)
+}
+
+//将实体标记为不可分配，只有全部块都空闲时才会成功
+func (e *entity) retire() bool {
+	return e.list.drain()
+}
+
+//释放mmap分配的内存，堆内存交给GC回收
+func (e *entity) free() error {
+	if !e.mapped {
+		return nil
+	}
+	e.mapped = false
+	return munmapMemory(e.memory)
+}
+
+func (e *entity) pop() []byte {
+	idx := e.list.pop()
+	if idx < 0 { //超出了实体的存储范围了
+		return nil
+	}
+	return e.chunks[idx].data
+}
+
+//计算指针对应的块序号
+func (e *entity) index(ptr uintptr) int {
+	offset := ptr - e.beginPtr
+	if offset%uintptr(e.esize) != 0 {
+		panic(ErrChunkNotAligned)
+	}
+	return int(offset / uintptr(e.esize))
+}
 
+//获取切片底层数组的指针
+func slicePtr(data []byte) uintptr {
+	return (*reflect.SliceHeader)(unsafe.Pointer(&data)).Data
 }
@@ -1,28 +1,35 @@
 package bytes_pool
 
 import (
+	"fmt"
 	. "github.com/tevid/gohamcrest"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestBytesPool_AllocAndRelease(t *testing.T) {
 	bp := NewBytesPool(512, 64*1024, 3*1024*1024)
 
 	for i := 0; i < len(bp.entityList); i++ {
-		tempData := make([][]byte, len(bp.entityList[i].chunks))
+		e := bp.entityList[i].load()[0]
+		tempData := make([][]byte, len(e.chunks))
 
 		for j := 0; j < len(tempData); j++ {
-			data := bp.Alloc(bp.entityList[i].esize)
-			Assert(t, cap(data), Equal(bp.entityList[i].esize))
+			data := bp.Alloc(e.esize)
+			Assert(t, cap(data), Equal(e.esize))
 			tempData[j] = data
 		}
 
-		Assert(t, bp.entityList[i].pos, Equal(uint64(0)))
+		Assert(t, e.list.isEmpty(), Equal(true))
 
-		for j := 0; j < len(bp.entityList); j++ {
+		for j := 0; j < len(tempData); j++ {
 			bp.Release(tempData[j])
 		}
-		Assert(t, bp.entityList[i].pos, Not(Equal(uint64(0))))
+		Assert(t, e.list.isEmpty(), Equal(false))
+		Assert(t, e.list.freeNum(), Equal(int64(len(e.chunks))))
 	}
 }
 
@@ -45,13 +52,162 @@ func TestBytesPool_Alloc_2(t *testing.T) {
 func TestBytesPool_Release_1(t *testing.T) {
 	bp := NewBytesPool(128, 1024, 1024)
 	mem := bp.Alloc(64)
-	go func() {
-		defer func() {
-			Assert(t, recover(), NilVal())
-		}()
-		bp.Release(mem)
-		bp.Release(mem)
+	bp.Release(mem)
+	Assert(t, releaseErr(bp, mem), Equal(ErrChunkReleased))
+}
+
+func TestBytesPool_Release_2(t *testing.T) {
+	bp := NewBytesPool(128, 1024, 1024)
+	mem := bp.Alloc(1024)
+	Assert(t, releaseErr(bp, mem[128:]), Equal(ErrChunkNotAligned))
+	Assert(t, releaseErr(bp, mem[512:]), Equal(ErrChunkNotAligned))
+	Assert(t, releaseErr(bp, mem), NilVal())
+
+	//池外分配的内存直接忽略
+	Assert(t, releaseErr(bp, make([]byte, 128)), NilVal())
+}
+
+//归还后依次取出的块互不相同，且链表不会丢失节点
+func TestBytesPool_Release_3(t *testing.T) {
+	bp := NewBytesPool(128, 128, 1024)
+	e := bp.entityList[0].load()[0]
+	var datas [][]byte
+	for i := 0; i < len(e.chunks); i++ {
+		datas = append(datas, bp.Alloc(128))
+	}
+	for _, data := range datas {
+		bp.Release(data)
+	}
+	seen := make(map[*byte]bool)
+	for i := 0; i < len(e.chunks); i++ {
+		data := bp.Alloc(128)
+		Assert(t, cap(data), Equal(128))
+		Assert(t, seen[&data[0]], Equal(false))
+		seen[&data[0]] = true
+	}
+}
+
+//并发分配归还，检查块不会被同时分配给两个协程，配合-race运行
+func TestBytesPool_Concurrent(t *testing.T) {
+	testConcurrent(t, NewBytesPool(64, 1024, 64*1024, WithMaxEntityNum(2)))
+}
+
+func TestBytesPool_ConcurrentWithLocalCache(t *testing.T) {
+	testConcurrent(t, NewBytesPool(64, 1024, 64*1024, WithMaxEntityNum(2), WithLocalCacheSize(4)))
+}
+
+func testConcurrent(t *testing.T, bp *BytesPool) {
+	defer bp.Close()
+
+	const goroutines = 64
+	const loops = 2000
+	var wg sync.WaitGroup
+	failed := make(chan int, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(id byte) {
+			defer wg.Done()
+			for i := 0; i < loops; i++ {
+				data := bp.Alloc(64 << uint(i%5))
+				for j := range data {
+					data[j] = id
+				}
+				runtime.Gosched()
+				for j := range data {
+					if data[j] != id {
+						failed <- int(id)
+						return
+					}
+				}
+				bp.Release(data)
+			}
+		}(byte(g))
+	}
+	wg.Wait()
+	close(failed)
+	Assert(t, len(failed), Equal(0))
+
+	bp.Shrink()
+	for _, group := range bp.entityList {
+		for _, e := range group.load() {
+			Assert(t, e.list.freeNum(), Equal(int64(len(e.chunks))))
+		}
+	}
+}
+
+func releaseErr(bp *BytesPool, data []byte) (err interface{}) {
+	defer func() {
+		err = recover()
 	}()
+	bp.Release(data)
+	return nil
+}
+
+func TestBytesPool_Grow(t *testing.T) {
+	bp := NewBytesPool(128, 128, 1024, WithMaxEntityNum(3))
+	defer bp.Close()
+	group := bp.entityList[0]
+
+	//3个实体，每个实体8块
+	var datas [][]byte
+	for i := 0; i < 3*group.chunkLen; i++ {
+		data := bp.Alloc(100)
+		Assert(t, cap(data), Equal(128))
+		datas = append(datas, data)
+	}
+	Assert(t, len(group.load()), Equal(3))
+
+	//超出上限后退化为make
+	data := bp.Alloc(100)
+	Assert(t, cap(data), Equal(100))
+
+	for _, data := range datas {
+		bp.Release(data)
+	}
+	for _, e := range group.load() {
+		Assert(t, e.list.freeNum(), Equal(int64(group.chunkLen)))
+	}
+}
+
+func TestBytesPool_Shrink(t *testing.T) {
+	bp := NewBytesPool(128, 128, 1024, WithMaxEntityNum(3))
+	defer bp.Close()
+	group := bp.entityList[0]
+
+	var datas [][]byte
+	for i := 0; i < 2*group.chunkLen+1; i++ {
+		datas = append(datas, bp.Alloc(128))
+	}
+	Assert(t, len(group.load()), Equal(3))
+
+	//仍有块未归还的实体不会被回收
+	for _, data := range datas[1:] {
+		bp.Release(data)
+	}
+	bp.Shrink()
+	Assert(t, len(group.load()), Equal(1))
+
+	bp.Release(datas[0])
+	bp.Shrink()
+	Assert(t, len(group.load()), Equal(1))
+}
+
+func TestBytesPool_LocalCache(t *testing.T) {
+	bp := NewBytesPool(128, 128, 1024, WithLocalCacheSize(2))
+	e := bp.entityList[0].load()[0]
+
+	data := bp.Alloc(128)
+	bp.Release(data)
+	//放入了本地缓存，没有回到全局链表
+	Assert(t, e.list.freeNum(), Equal(int64(len(e.chunks)-1)))
+	Assert(t, releaseErr(bp, data), Equal(ErrChunkReleased))
+
+	again := bp.Alloc(128)
+	Assert(t, &again[0], Equal(&data[0]))
+	bp.Release(again)
+
+	bp.Shrink()
+	Assert(t, e.list.freeNum(), Equal(int64(len(e.chunks))))
 }
 
 func BenchmarkBytesPool_AllocAndRelease(b *testing.B) {
@@ -74,3 +230,135 @@ func Benchmark_AllocAndRelease(b *testing.B) {
 		x = x[:0]
 	})
 }
+
+//对比BytesPool、sync.Pool与make在不同并发数下的表现
+func BenchmarkCompare(b *testing.B) {
+	const size = 4096
+	for _, goroutines := range []int{1, 8, 64} {
+		bp := NewBytesPool(size, size, 256*size)
+		b.Run(fmt.Sprintf("BytesPool/%d", goroutines), func(b *testing.B) {
+			benchGoroutines(b, goroutines, func() {
+				bp.Release(bp.Alloc(size))
+			})
+		})
+
+		cached := NewBytesPool(size, size, 256*size, WithLocalCacheSize(8))
+		b.Run(fmt.Sprintf("BytesPoolLocalCache/%d", goroutines), func(b *testing.B) {
+			benchGoroutines(b, goroutines, func() {
+				cached.Release(cached.Alloc(size))
+			})
+		})
+
+		sp := sync.Pool{New: func() interface{} {
+			data := make([]byte, size)
+			return &data
+		}}
+		b.Run(fmt.Sprintf("SyncPool/%d", goroutines), func(b *testing.B) {
+			benchGoroutines(b, goroutines, func() {
+				sp.Put(sp.Get())
+			})
+		})
+
+		b.Run(fmt.Sprintf("Make/%d", goroutines), func(b *testing.B) {
+			benchGoroutines(b, goroutines, func() {
+				benchSink = make([]byte, size)
+			})
+		})
+	}
+}
+
+var benchSink []byte
+
+//以固定数量的协程分摊执行b.N次fn
+func benchGoroutines(b *testing.B, goroutines int, fn func()) {
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	b.ResetTimer()
+	for g := 0; g < goroutines; g++ {
+		n := b.N / goroutines
+		if g < b.N%goroutines {
+			n++
+		}
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				fn()
+			}
+		}(n)
+	}
+	wg.Wait()
+}
+
+func TestBytesPool_Leaks(t *testing.T) {
+	bp := NewBytesPool(128, 1024, 4096, WithLeakDetection(0))
+	kept := bp.Alloc(100)
+	released := bp.Alloc(200)
+	bp.Release(released)
+
+	leaks := bp.Leaks()
+	Assert(t, len(leaks), Equal(1))
+	Assert(t, leaks[0].Size, Equal(cap(kept)))
+	Assert(t, strings.Contains(leaks[0].Stack, "TestBytesPool_Leaks"), Equal(true))
+
+	bp.Release(kept)
+	Assert(t, len(bp.Leaks()), Equal(0))
+
+	//未超过阈值的不报告
+	bp = NewBytesPool(128, 1024, 4096, WithLeakDetection(time.Hour))
+	bp.Alloc(100)
+	Assert(t, len(bp.Leaks()), Equal(0))
+}
+
+func TestBytesPool_UseAfterRelease(t *testing.T) {
+	bp := NewBytesPool(128, 128, 128, WithLeakDetection(time.Minute))
+	data := bp.Alloc(128)
+	bp.Release(data)
+	Assert(t, data[0], Equal(byte(POISON_BYTE)))
+
+	data[10] = 1
+	bp.Alloc(128)
+	violations := bp.UseAfterRelease()
+	Assert(t, len(violations), Equal(1))
+	Assert(t, violations[0].Offset, Equal(10))
+	Assert(t, strings.Contains(violations[0].ReleaseStack, "TestBytesPool_UseAfterRelease"), Equal(true))
+}
+
+func TestBytesPool_SizeClasses(t *testing.T) {
+	bp := NewBytesPool(0, 0, 8192, WithSizeClasses(3000, 100, 1500, 100, 9000))
+	Assert(t, len(bp.entityList), Equal(3))
+
+	Assert(t, cap(bp.Alloc(1)), Equal(100))
+	Assert(t, cap(bp.Alloc(101)), Equal(1500))
+	Assert(t, cap(bp.Alloc(1500)), Equal(1500))
+	Assert(t, cap(bp.Alloc(1501)), Equal(3000))
+	Assert(t, cap(bp.Alloc(3001)), Equal(3001))
+
+	data := bp.Alloc(1200)
+	e := bp.entityList[1].load()[0]
+	free := e.list.freeNum()
+	bp.Release(data)
+	Assert(t, e.list.freeNum(), Equal(free+1))
+}
+
+func TestBytesPool_GeometricClasses(t *testing.T) {
+	bp := NewBytesPool(1024, 4096, 64*1024, WithGeometricClasses(4))
+	var sizes []int
+	for _, group := range bp.entityList {
+		sizes = append(sizes, group.esize)
+	}
+	Assert(t, sizes, Equal([]int{1024, 1280, 1536, 1792, 2048, 2560, 3072, 3584, 4096}))
+
+	Assert(t, cap(bp.Alloc(1025)), Equal(1280))
+	Assert(t, cap(bp.Alloc(2049)), Equal(2560))
+
+	//所有大小都能找到最小的可容纳类别
+	for size := 0; size <= 4096; size++ {
+		idx := bp.classIndex(size)
+		Assert(t, bp.entityList[idx].esize >= size, Equal(true))
+		if idx > 0 {
+			Assert(t, bp.entityList[idx-1].esize < size, Equal(true))
+		}
+	}
+	Assert(t, bp.classIndex(4097), Equal(-1))
+}
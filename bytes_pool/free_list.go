@@ -0,0 +1,110 @@
+package bytes_pool
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+const (
+	NODE_FREE  = 0 //节点在空闲链表中
+	NODE_INUSE = 1 //节点已被分配
+)
+
+//无锁空闲链表
+//head高32位为栈顶节点序号+INIT_POS（0表示链表为空），低32位为版本号，每次修改head都会递增，用于规避ABA问题
+type freeList struct {
+	head  uint64
+	next  []uint32 //每个节点的后继节点序号+INIT_POS，0表示链表尾
+	state []uint32 //每个节点的分配状态
+	free  int64    //空闲节点数量
+}
+
+func newFreeList(n int) *freeList {
+	l := &freeList{
+		next:  make([]uint32, n),
+		state: make([]uint32, n),
+		free:  int64(n),
+	}
+	for i := 0; i < n-1; i++ {
+		l.next[i] = uint32(i + 1 + INIT_POS)
+	}
+	if n > 0 {
+		l.head = INIT_POS << POS_SHIFT
+	}
+	return l
+}
+
+func makeHead(posIndex uint32, version uint64) uint64 {
+	return uint64(posIndex)<<POS_SHIFT | (version+1)&(1<<POS_SHIFT-1)
+}
+
+//取出一个空闲节点，链表为空时返回-1
+func (l *freeList) pop() int {
+	for {
+		currentHead := atomic.LoadUint64(&l.head)
+		posIndex := uint32(currentHead >> POS_SHIFT)
+		if posIndex == 0 {
+			return -1
+		}
+		idx := int(posIndex - INIT_POS)
+		//节点被其他协程取走后next可能被改写，但此时head的版本号已变化，CAS会失败
+		nextIndex := atomic.LoadUint32(&l.next[idx])
+		if atomic.CompareAndSwapUint64(&l.head, currentHead, makeHead(nextIndex, currentHead)) {
+			atomic.StoreUint32(&l.state[idx], NODE_INUSE)
+			atomic.AddInt64(&l.free, -1)
+			return idx
+		}
+		runtime.Gosched()
+	}
+}
+
+//归还节点，节点已经处于空闲状态时返回false
+func (l *freeList) push(idx int) bool {
+	if !l.release(idx) {
+		return false
+	}
+	l.link(idx)
+	return true
+}
+
+//把节点标记为空闲，节点已经处于空闲状态时返回false
+func (l *freeList) release(idx int) bool {
+	return atomic.CompareAndSwapUint32(&l.state[idx], NODE_INUSE, NODE_FREE)
+}
+
+//把节点标记为已分配，用于不经过链表直接复用的空闲节点
+func (l *freeList) acquire(idx int) {
+	atomic.StoreUint32(&l.state[idx], NODE_INUSE)
+}
+
+//把已标记为空闲的节点放回链表
+func (l *freeList) link(idx int) {
+	for {
+		currentHead := atomic.LoadUint64(&l.head)
+		atomic.StoreUint32(&l.next[idx], uint32(currentHead>>POS_SHIFT))
+		if atomic.CompareAndSwapUint64(&l.head, currentHead, makeHead(uint32(idx+INIT_POS), currentHead)) {
+			atomic.AddInt64(&l.free, 1)
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+//所有节点都空闲时清空链表，之后pop将始终返回-1
+func (l *freeList) drain() bool {
+	currentHead := atomic.LoadUint64(&l.head)
+	if atomic.LoadInt64(&l.free) != int64(len(l.next)) {
+		return false
+	}
+	//push先修改head再递增free，读到free满时head必然已是最终状态；
+	//之后的任何pop都会改变head的版本号，使下面的CAS失败
+	return atomic.CompareAndSwapUint64(&l.head, currentHead, makeHead(0, currentHead))
+}
+
+func (l *freeList) freeNum() int64 {
+	return atomic.LoadInt64(&l.free)
+}
+
+func (l *freeList) isEmpty() bool {
+	return atomic.LoadUint64(&l.head)>>POS_SHIFT == 0
+}
@@ -0,0 +1,204 @@
+package bytes_pool
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	POISON_BYTE     = 0xdb //归还后填充的字节，重新分配时检查是否被改写
+	MAX_STACK_DEPTH = 32
+)
+
+type (
+	//未归还的块
+	LeakInfo struct {
+		Size    int       //块大小
+		AllocAt time.Time //分配时间
+		Held    time.Duration
+		Stack   string //分配时的调用栈
+	}
+
+	//归还后仍被写入的块
+	UseAfterReleaseInfo struct {
+		Size         int
+		Offset       int       //第一个被改写的字节位置
+		ReleaseAt    time.Time //归还时间
+		ReleaseStack string    //归还时的调用栈
+		AllocStack   string    //重新分配时的调用栈
+	}
+
+	//泄漏检测，只用于调试，会显著降低分配性能
+	leakTracker struct {
+		threshold time.Duration
+
+		mu         sync.Mutex
+		inuse      map[uintptr]*allocRecord //已分配未归还的块
+		released   map[uintptr]*allocRecord //已归还并填充了POISON_BYTE的块
+		violations []UseAfterReleaseInfo
+	}
+
+	allocRecord struct {
+		size  int
+		at    time.Time
+		stack []uintptr
+	}
+)
+
+//开启泄漏检测：记录每个块的分配调用栈，Leaks报告持有超过threshold的块；
+//归还时填充POISON_BYTE，重新分配时检查内存是否在归还后被改写
+func WithLeakDetection(threshold time.Duration) Option {
+	return func(p *BytesPool) {
+		p.tracker = newLeakTracker(threshold)
+	}
+}
+
+func newLeakTracker(threshold time.Duration) *leakTracker {
+	return &leakTracker{
+		threshold: threshold,
+		inuse:     make(map[uintptr]*allocRecord),
+		released:  make(map[uintptr]*allocRecord),
+	}
+}
+
+//持有时间超过阈值仍未归还的块，按分配时间排序；未开启泄漏检测时返回nil
+func (p *BytesPool) Leaks() []LeakInfo {
+	if p.tracker == nil {
+		return nil
+	}
+	return p.tracker.leaks(time.Now())
+}
+
+//检测到的归还后写入，未开启泄漏检测时返回nil
+func (p *BytesPool) UseAfterRelease() []UseAfterReleaseInfo {
+	if p.tracker == nil {
+		return nil
+	}
+	t := p.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]UseAfterReleaseInfo(nil), t.violations...)
+}
+
+func (l LeakInfo) String() string {
+	return fmt.Sprintf("%d bytes held for %v, allocated at:\n%s", l.Size, l.Held, l.Stack)
+}
+
+func (u UseAfterReleaseInfo) String() string {
+	return fmt.Sprintf("%d bytes chunk modified at offset %d after release, released at:\n%s\nreallocated at:\n%s",
+		u.Size, u.Offset, u.ReleaseStack, u.AllocStack)
+}
+
+//skip为需要跳过的调用层数，不包含runtime.Callers和newAllocRecord本身
+func newAllocRecord(size, skip int) *allocRecord {
+	pcs := make([]uintptr, MAX_STACK_DEPTH)
+	n := runtime.Callers(skip+2, pcs)
+	return &allocRecord{size: size, at: time.Now(), stack: pcs[:n]}
+}
+
+//分配时记录调用栈，并检查归还期间内存是否被改写
+func (t *leakTracker) alloc(data []byte) {
+	data = data[:cap(data)]
+	//跳过leakTracker.alloc、BytesPool.Alloc
+	rec := newAllocRecord(len(data), 2)
+	ptr := slicePtr(data)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if released, ok := t.released[ptr]; ok {
+		delete(t.released, ptr)
+		for i, b := range data {
+			if b != POISON_BYTE {
+				t.violations = append(t.violations, UseAfterReleaseInfo{
+					Size:         len(data),
+					Offset:       i,
+					ReleaseAt:    released.at,
+					ReleaseStack: formatStack(released.stack),
+					AllocStack:   formatStack(rec.stack),
+				})
+				break
+			}
+		}
+	}
+	t.inuse[ptr] = rec
+}
+
+//归还时填充POISON_BYTE，调用方需保证块已经通过了重复归还检查
+func (t *leakTracker) release(data []byte) {
+	data = data[:cap(data)]
+	for i := range data {
+		data[i] = POISON_BYTE
+	}
+	//跳过leakTracker.release、entityGroup.push、BytesPool.Release
+	rec := newAllocRecord(len(data), 3)
+	ptr := slicePtr(data)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.inuse, ptr)
+	t.released[ptr] = rec
+}
+
+//记录非字节块对象的分配，skip为需要跳过的调用层数，不包含track本身
+func (t *leakTracker) track(ptr uintptr, size, skip int) {
+	rec := newAllocRecord(size, skip+1)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inuse[ptr] = rec
+}
+
+func (t *leakTracker) untrack(ptr uintptr) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.inuse, ptr)
+}
+
+func (t *leakTracker) leaks(now time.Time) []LeakInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var result []LeakInfo
+	for _, rec := range t.inuse {
+		held := now.Sub(rec.at)
+		if held < t.threshold {
+			continue
+		}
+		result = append(result, LeakInfo{
+			Size:    rec.size,
+			AllocAt: rec.at,
+			Held:    held,
+			Stack:   formatStack(rec.stack),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].AllocAt.Before(result[j].AllocAt)
+	})
+	return result
+}
+
+//实体被回收后，删除其内存范围内的记录，避免新实体复用同一地址时误报
+func (t *leakTracker) forget(e *entity) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ptr := range t.released {
+		if e.beginPtr <= ptr && ptr < e.endPtr {
+			delete(t.released, ptr)
+		}
+	}
+}
+
+func formatStack(pcs []uintptr) string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
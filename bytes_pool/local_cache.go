@@ -0,0 +1,117 @@
+package bytes_pool
+
+import (
+	"runtime"
+	"sync"
+	_ "unsafe"
+)
+
+const (
+	CACHE_LINE_SIZE = 64
+)
+
+//按P分片的本地缓存，思路与sync.Pool的poolLocal一致：
+//协程只访问当前P对应的分片，锁基本不会发生竞争，避免所有协程CAS同一个全局链表头
+type (
+	localCache struct {
+		mu    sync.Mutex
+		items []localItem
+		_     [CACHE_LINE_SIZE]byte //避免相邻分片的伪共享
+	}
+
+	localItem struct {
+		e   *entity
+		idx int
+	}
+)
+
+//go:linkname runtime_procPin runtime.procPin
+func runtime_procPin() int
+
+//go:linkname runtime_procUnpin runtime.procUnpin
+func runtime_procUnpin()
+
+func newLocalCaches(size int) []localCache {
+	locals := make([]localCache, runtime.GOMAXPROCS(0))
+	for i := range locals {
+		locals[i].items = make([]localItem, 0, size)
+	}
+	return locals
+}
+
+//当前P对应的分片
+func (g *entityGroup) local() *localCache {
+	pid := runtime_procPin()
+	runtime_procUnpin()
+	return &g.locals[pid%len(g.locals)]
+}
+
+func (g *entityGroup) popLocal() []byte {
+	if g.locals == nil {
+		return nil
+	}
+	l := g.local()
+	l.mu.Lock()
+	item, ok := l.pop()
+	l.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	item.e.list.acquire(item.idx)
+	return item.e.chunks[item.idx].data
+}
+
+//放入当前P的本地缓存，缓存已满时返回false
+func (g *entityGroup) pushLocal(e *entity, idx int) bool {
+	if g.locals == nil {
+		return false
+	}
+	l := g.local()
+	l.mu.Lock()
+	ok := len(l.items) < cap(l.items)
+	if ok {
+		l.items = append(l.items, localItem{e: e, idx: idx})
+	}
+	l.mu.Unlock()
+	return ok
+}
+
+//全局链表耗尽时，从其他P的本地缓存中窃取，正被占用的分片直接跳过
+func (g *entityGroup) steal() []byte {
+	for i := range g.locals {
+		l := &g.locals[i]
+		if !l.mu.TryLock() {
+			continue
+		}
+		item, ok := l.pop()
+		l.mu.Unlock()
+		if ok {
+			item.e.list.acquire(item.idx)
+			return item.e.chunks[item.idx].data
+		}
+	}
+	return nil
+}
+
+//把所有本地缓存的块放回全局链表
+func (g *entityGroup) flush() {
+	for i := range g.locals {
+		l := &g.locals[i]
+		l.mu.Lock()
+		for _, item := range l.items {
+			item.e.list.link(item.idx)
+		}
+		l.items = l.items[:0]
+		l.mu.Unlock()
+	}
+}
+
+func (l *localCache) pop() (localItem, bool) {
+	n := len(l.items)
+	if n == 0 {
+		return localItem{}, false
+	}
+	item := l.items[n-1]
+	l.items = l.items[:n-1]
+	return item, true
+}
@@ -0,0 +1,55 @@
+package bytes_pool
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	MMAP_ENABLE    = 1 << iota //使用匿名mmap分配实体内存
+	MMAP_HUGE_PAGE             //建议内核使用透明大页
+	MMAP_LOCK                  //锁定内存，避免被换出
+)
+
+var (
+	ErrMmapUnsupported = errors.New("当前系统不支持mmap分配实体内存")
+	ErrMmapFallback    = errors.New("mmap失败，实体内存退化为在堆上分配")
+	ErrMmapAdvise      = errors.New("透明大页或锁定内存没有生效")
+)
+
+//实体内存改为通过匿名mmap在堆外分配，GC不再扫描这部分内存；flags为MMAP_HUGE_PAGE、MMAP_LOCK的组合
+//不支持mmap的系统或mmap失败时退化为在堆上分配；堆外内存在实体被回收或Close时释放
+//退化和透明大页、锁定内存的失败（如超过RLIMIT_MEMLOCK）通过MmapErr获取
+func WithMmap(flags int) Option {
+	return func(p *BytesPool) {
+		p.mmapFlags = flags | MMAP_ENABLE
+	}
+}
+
+//第一次分配实体内存时mmap相关的失败，errors.Is可判断为ErrMmapFallback或ErrMmapAdvise；没有失败时返回nil
+func (p *BytesPool) MmapErr() error {
+	p.mmapErrMu.Lock()
+	defer p.mmapErrMu.Unlock()
+	return p.mmapErr
+}
+
+//分配实体内存，失败时退化为在堆上分配并记录错误
+func (p *BytesPool) allocMemory() ([]byte, bool) {
+	memory, err := mmapMemory(p.memorySize, p.mmapFlags)
+	if memory == nil {
+		p.setMmapErr(fmt.Errorf("%w: %v", ErrMmapFallback, err))
+		return make([]byte, p.memorySize), false
+	}
+	if err != nil {
+		p.setMmapErr(fmt.Errorf("%w: %v", ErrMmapAdvise, err))
+	}
+	return memory, true
+}
+
+func (p *BytesPool) setMmapErr(err error) {
+	p.mmapErrMu.Lock()
+	defer p.mmapErrMu.Unlock()
+	if p.mmapErr == nil {
+		p.mmapErr = err
+	}
+}
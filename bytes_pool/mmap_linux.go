@@ -0,0 +1,32 @@
+// +build linux
+
+package bytes_pool
+
+import (
+	"fmt"
+	"syscall"
+)
+
+//通过匿名mmap分配堆外内存，GC不会扫描这部分内存，也不计入GOGC
+//透明大页或锁定内存失败时仍然返回普通的映射，同时返回错误
+func mmapMemory(size int, flags int) ([]byte, error) {
+	data, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	if flags&MMAP_HUGE_PAGE != 0 {
+		if adviseErr := syscall.Madvise(data, syscall.MADV_HUGEPAGE); adviseErr != nil {
+			err = fmt.Errorf("madvise: %w", adviseErr)
+		}
+	}
+	if flags&MMAP_LOCK != 0 {
+		if lockErr := syscall.Mlock(data); lockErr != nil && err == nil {
+			err = fmt.Errorf("mlock: %w", lockErr)
+		}
+	}
+	return data, err
+}
+
+func munmapMemory(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,67 @@
+// +build linux
+
+package bytes_pool
+
+import (
+	"errors"
+	. "github.com/tevid/gohamcrest"
+	"strings"
+	"testing"
+)
+
+func TestBytesPool_Mmap(t *testing.T) {
+	bp := NewBytesPool(128, 1024, 4096, WithMmap(MMAP_HUGE_PAGE|MMAP_LOCK), WithMaxEntityNum(2))
+	group := bp.entityList[0]
+	Assert(t, group.load()[0].mapped, Equal(true))
+	//透明大页或锁定内存可能因内核配置或RLIMIT_MEMLOCK失败，失败时能通过MmapErr获取
+	if err := bp.MmapErr(); err != nil {
+		Assert(t, errors.Is(err, ErrMmapAdvise), Equal(true))
+	}
+
+	var datas [][]byte
+	for i := 0; i < group.chunkLen+1; i++ {
+		data := bp.Alloc(128)
+		data[0] = byte(i)
+		datas = append(datas, data)
+	}
+	Assert(t, len(group.load()), Equal(2))
+	for _, data := range datas {
+		bp.Release(data)
+	}
+
+	//回收扩容实体时释放映射
+	bp.Shrink()
+	Assert(t, len(group.load()), Equal(1))
+
+	Assert(t, bp.Close(), NilVal())
+	Assert(t, group.load()[0].mapped, Equal(false))
+	//关闭后退化为make
+	Assert(t, cap(bp.Alloc(100)), Equal(100))
+}
+
+func TestBytesPool_MmapLockLimit(t *testing.T) {
+	//超过RLIMIT_MEMLOCK的实体锁定失败，仍使用mmap映射并报告错误
+	bp := NewBytesPool(1<<20, 1<<20, 64<<20, WithMmap(MMAP_LOCK))
+	defer bp.Close()
+	Assert(t, bp.entityList[0].load()[0].mapped, Equal(true))
+	if bp.MmapErr() == nil {
+		t.Skip("RLIMIT_MEMLOCK未限制")
+	}
+	Assert(t, errors.Is(bp.MmapErr(), ErrMmapAdvise), Equal(true))
+	Assert(t, strings.Contains(bp.MmapErr().Error(), "mlock"), Equal(true))
+}
+
+func TestBytesPool_MmapCloseInUse(t *testing.T) {
+	bp := NewBytesPool(128, 256, 4096, WithMmap(0), WithLocalCacheSize(4), WithLeakDetection(0))
+	data := bp.Alloc(128)
+	bp.Release(bp.Alloc(256))
+
+	//未归还块的实体不释放，块仍可写入和归还
+	err := bp.Close()
+	Assert(t, errors.Is(err, ErrChunksInUse), Equal(true))
+	Assert(t, bp.entityList[0].load()[0].mapped, Equal(true))
+	Assert(t, bp.entityList[1].load()[0].mapped, Equal(false))
+	data[0] = 1
+	bp.Release(data)
+	Assert(t, bp.MmapErr(), NilVal())
+}
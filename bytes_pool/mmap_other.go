@@ -0,0 +1,11 @@
+// +build !linux
+
+package bytes_pool
+
+func mmapMemory(size int, flags int) ([]byte, error) {
+	return nil, ErrMmapUnsupported
+}
+
+func munmapMemory(data []byte) error {
+	return ErrMmapUnsupported
+}
@@ -0,0 +1,154 @@
+package bytes_pool
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+var (
+	ErrObjectReleased = errors.New("object had been release")
+)
+
+type (
+	//定长的类型化对象池，与BytesPool的实体相同：对象在连续数组中预先分配，通过无锁空闲链表复用
+	//池耗尽时Get退化为new，这类对象Put时直接丢弃
+	ObjectPool[T any] struct {
+		objects  []T
+		list     *freeList
+		beginPtr uintptr
+		endPtr   uintptr
+		size     uintptr
+
+		newFn   func(*T) //对象初始化
+		resetFn func(*T) //对象归还时重置
+		tracker *leakTracker
+
+		gets     int64
+		puts     int64
+		misses   int64
+		discards int64
+	}
+
+	//对象池统计
+	ObjectPoolStats struct {
+		Capacity int   //池容量
+		InUse    int   //池中已分配未归还的对象数量
+		Gets     int64 //Get次数
+		Puts     int64 //Put次数
+		Misses   int64 //池耗尽时Get新建对象的次数
+		Discards int64 //Put了池外对象而被丢弃的次数
+	}
+
+	//对象池选项
+	ObjectPoolOption func(*objectPoolOptions)
+
+	objectPoolOptions struct {
+		tracker *leakTracker
+	}
+)
+
+//开启泄漏检测，Leaks报告持有超过threshold仍未归还的对象
+func WithObjectLeakDetection(threshold time.Duration) ObjectPoolOption {
+	return func(o *objectPoolOptions) {
+		o.tracker = newLeakTracker(threshold)
+	}
+}
+
+//构建容量为capacity的对象池
+//newFn在对象首次创建时调用，resetFn在对象归还时调用，均可为nil
+func NewObjectPool[T any](capacity int, newFn, resetFn func(*T), opts ...ObjectPoolOption) *ObjectPool[T] {
+	var options objectPoolOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var zero T
+	p := &ObjectPool[T]{
+		size:    unsafe.Sizeof(zero),
+		newFn:   newFn,
+		resetFn: resetFn,
+		tracker: options.tracker,
+	}
+	//零大小的类型所有对象地址相同，无法区分，不做池化
+	if p.size == 0 || capacity < 0 {
+		capacity = 0
+	}
+	p.objects = make([]T, capacity)
+	p.list = newFreeList(capacity)
+	if capacity > 0 {
+		p.beginPtr = uintptr(unsafe.Pointer(&p.objects[0]))
+		p.endPtr = p.beginPtr + uintptr(capacity)*p.size
+	}
+	if newFn != nil {
+		for i := range p.objects {
+			newFn(&p.objects[i])
+		}
+	}
+	return p
+}
+
+//从池中获取对象
+func (p *ObjectPool[T]) Get() *T {
+	atomic.AddInt64(&p.gets, 1)
+	idx := p.list.pop()
+	if idx < 0 {
+		atomic.AddInt64(&p.misses, 1)
+		obj := new(T)
+		if p.newFn != nil {
+			p.newFn(obj)
+		}
+		return obj
+	}
+	obj := &p.objects[idx]
+	if p.tracker != nil {
+		//跳过ObjectPool.Get
+		p.tracker.track(uintptr(unsafe.Pointer(obj)), int(p.size), 1)
+	}
+	return obj
+}
+
+//把对象归还回池中，重复归还时panic
+func (p *ObjectPool[T]) Put(obj *T) {
+	if obj == nil {
+		return
+	}
+	atomic.AddInt64(&p.puts, 1)
+	ptr := uintptr(unsafe.Pointer(obj))
+	if ptr < p.beginPtr || ptr >= p.endPtr {
+		atomic.AddInt64(&p.discards, 1)
+		return
+	}
+	idx := int((ptr - p.beginPtr) / p.size)
+	//先标记为空闲再重置，避免重复归还时重置了其他协程正在使用的对象
+	if !p.list.release(idx) {
+		panic(ErrObjectReleased)
+	}
+	if p.tracker != nil {
+		p.tracker.untrack(ptr)
+	}
+	if p.resetFn != nil {
+		p.resetFn(obj)
+	}
+	p.list.link(idx)
+}
+
+func (p *ObjectPool[T]) Stats() ObjectPoolStats {
+	return ObjectPoolStats{
+		Capacity: len(p.objects),
+		InUse:    len(p.objects) - int(p.list.freeNum()),
+		Gets:     atomic.LoadInt64(&p.gets),
+		Puts:     atomic.LoadInt64(&p.puts),
+		Misses:   atomic.LoadInt64(&p.misses),
+		Discards: atomic.LoadInt64(&p.discards),
+	}
+}
+
+//持有时间超过阈值仍未归还的对象，未开启泄漏检测时返回nil
+func (p *ObjectPool[T]) Leaks() []LeakInfo {
+	if p.tracker == nil {
+		return nil
+	}
+	return p.tracker.leaks(time.Now())
+}
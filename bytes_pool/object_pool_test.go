@@ -0,0 +1,101 @@
+package bytes_pool
+
+import (
+	. "github.com/tevid/gohamcrest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testMessage struct {
+	ID     int
+	Fields []string
+}
+
+func TestObjectPool_GetAndPut(t *testing.T) {
+	resets := 0
+	pool := NewObjectPool(2, func(m *testMessage) {
+		m.Fields = make([]string, 0, 8)
+	}, func(m *testMessage) {
+		resets++
+		m.ID = 0
+		m.Fields = m.Fields[:0]
+	})
+
+	m1 := pool.Get()
+	m1.ID = 1
+	m1.Fields = append(m1.Fields, "a")
+	m2 := pool.Get()
+	//池耗尽后新建对象，同样经过newFn初始化
+	m3 := pool.Get()
+	Assert(t, cap(m3.Fields), Equal(8))
+
+	stats := pool.Stats()
+	Assert(t, stats.InUse, Equal(2))
+	Assert(t, stats.Misses, Equal(int64(1)))
+
+	pool.Put(m1)
+	pool.Put(m2)
+	pool.Put(m3)
+	Assert(t, resets, Equal(2))
+
+	stats = pool.Stats()
+	Assert(t, stats.InUse, Equal(0))
+	Assert(t, stats.Puts, Equal(int64(3)))
+	Assert(t, stats.Discards, Equal(int64(1)))
+
+	m := pool.Get()
+	Assert(t, m == m1 || m == m2, Equal(true))
+	Assert(t, m.ID, Equal(0))
+	Assert(t, len(m.Fields), Equal(0))
+}
+
+func TestObjectPool_DoublePut(t *testing.T) {
+	pool := NewObjectPool[testMessage](1, nil, nil)
+	m := pool.Get()
+	pool.Put(m)
+
+	defer func() {
+		Assert(t, recover(), Equal(ErrObjectReleased))
+	}()
+	pool.Put(m)
+}
+
+func TestObjectPool_Leaks(t *testing.T) {
+	pool := NewObjectPool[testMessage](2, nil, nil, WithObjectLeakDetection(0))
+	m := pool.Get()
+	leaks := pool.Leaks()
+	Assert(t, len(leaks), Equal(1))
+	Assert(t, strings.Contains(leaks[0].Stack, "TestObjectPool_Leaks"), Equal(true))
+
+	pool.Put(m)
+	Assert(t, len(pool.Leaks()), Equal(0))
+}
+
+func TestObjectPool_Concurrent(t *testing.T) {
+	pool := NewObjectPool[testMessage](16, nil, func(m *testMessage) {
+		m.ID = 0
+	})
+
+	var wg sync.WaitGroup
+	failed := make(chan int, 32)
+	for g := 1; g <= 32; g++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				m := pool.Get()
+				if m.ID != 0 {
+					failed <- id
+					return
+				}
+				m.ID = id
+				pool.Put(m)
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(failed)
+	Assert(t, len(failed), Equal(0))
+	Assert(t, pool.Stats().InUse, Equal(0))
+}
@@ -0,0 +1,107 @@
+package bytes_pool
+
+import (
+	"sort"
+)
+
+const (
+	DEFAULT_CLASS_STEPS = 1    //每翻倍一次划分的大小类别数，1表示按2的幂划分
+	MAX_LOOKUP_SIZE     = 4096 //大小类别查找表的最大长度
+)
+
+//使用指定的大小类别，忽略NewBytesPool的initSize和maxSize
+//大于memorySize的类别会被忽略
+func WithSizeClasses(sizes ...int) Option {
+	return func(p *BytesPool) {
+		p.classSizes = append([]int(nil), sizes...)
+	}
+}
+
+//在initSize到maxSize之间按几何间隔划分大小类别，每翻倍一次划分steps个类别，
+//例如steps为4时：1024、1280、1536、1792、2048...，减少非2的幂大小请求浪费的内存
+func WithGeometricClasses(steps int) Option {
+	return func(p *BytesPool) {
+		if steps > 0 {
+			p.classSteps = steps
+		}
+	}
+}
+
+//生成几何间隔的大小类别
+func geometricSizes(initSize, maxSize, steps int) []int {
+	var sizes []int
+	if initSize <= 0 {
+		return sizes
+	}
+	for base := initSize; base <= maxSize; base *= DEFAULT_INCREATE_FACTOR {
+		step := base / steps
+		if step == 0 {
+			step = 1
+		}
+		for size := base; size < base*DEFAULT_INCREATE_FACTOR && size <= maxSize; size += step {
+			sizes = append(sizes, size)
+		}
+	}
+	return sizes
+}
+
+//排序、去重并过滤无法在一个实体中容纳的类别
+func normalizeSizes(sizes []int, memorySize int) []int {
+	sort.Ints(sizes)
+	result := sizes[:0]
+	for _, size := range sizes {
+		if size <= 0 || size > memorySize {
+			continue
+		}
+		if len(result) > 0 && result[len(result)-1] == size {
+			continue
+		}
+		result = append(result, size)
+	}
+	return result
+}
+
+//大小类别查找表
+//table[k]为能容纳((k-1)<<shift, k<<shift]区间内最小请求的类别，查到后最多向后修正几个类别
+type classLookup struct {
+	shift uint
+	table []uint16
+}
+
+func newClassLookup(sizes []int) classLookup {
+	var l classLookup
+	if len(sizes) == 0 {
+		return l
+	}
+	maxSize := sizes[len(sizes)-1]
+	for maxSize>>l.shift > MAX_LOOKUP_SIZE {
+		l.shift++
+	}
+	l.table = make([]uint16, (maxSize+(1<<l.shift)-1)>>l.shift+1)
+	idx := 0
+	for k := range l.table {
+		//区间内的最小请求
+		lower := 1
+		if k > 0 {
+			lower = (k-1)<<l.shift + 1
+		}
+		for idx < len(sizes)-1 && sizes[idx] < lower {
+			idx++
+		}
+		l.table[k] = uint16(idx)
+	}
+	return l
+}
+
+//查找能容纳size的最小类别，没有时返回-1
+func (p *BytesPool) classIndex(size int) int {
+	if len(p.entityList) == 0 || size > p.entityList[len(p.entityList)-1].esize {
+		return -1
+	}
+	k := (size + (1 << p.lookup.shift) - 1) >> p.lookup.shift
+	idx := int(p.lookup.table[k])
+	for p.entityList[idx].esize < size {
+		idx++
+	}
+	return idx
+}
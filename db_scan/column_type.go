@@ -0,0 +1,272 @@
+package db_scan
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrValueOverflow = errors.New("数值超出字段类型的范围")
+	ErrValueSign     = errors.New("负数无法转换为无符号整数")
+	ErrPrecisionLoss = errors.New("小数无法无损转换为整数")
+)
+
+//列的数值类别，由ColumnTypes的DatabaseTypeName推断
+type columnKind int
+
+const (
+	columnUnknown columnKind = iota
+	columnInteger
+	columnFloat
+	columnDecimal
+)
+
+type (
+	//列转换到字段失败的错误，可通过errors.Is判断具体原因
+	ColumnError struct {
+		Column string
+		Field  string //字段路径，如Author.ID，扫描到非结构体时为空
+		Err    error
+	}
+
+	//能提供列类型的结果集，*sql.Rows实现了该接口
+	columnTypeRows interface {
+		ColumnTypes() ([]*sql.ColumnType, error)
+	}
+)
+
+var ratType = reflect.TypeOf(big.Rat{})
+
+func (e *ColumnError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("列%s转换失败: %v", e.Column, e.Err)
+	}
+	return fmt.Sprintf("列%s转换到字段%s失败: %v", e.Column, e.Field, e.Err)
+}
+
+func (e *ColumnError) Unwrap() error {
+	return e.Err
+}
+
+//各列的数值类别，结果集不支持ColumnTypes时返回nil
+func columnKinds(rows IRows) []columnKind {
+	r, ok := rows.(columnTypeRows)
+	if !ok {
+		return nil
+	}
+	types, err := r.ColumnTypes()
+	if nil != err {
+		return nil
+	}
+	kinds := make([]columnKind, len(types))
+	for i, columnType := range types {
+		kinds[i] = parseColumnKind(columnType.DatabaseTypeName())
+	}
+	return kinds
+}
+
+func kindAt(kinds []columnKind, i int) columnKind {
+	if i < len(kinds) {
+		return kinds[i]
+	}
+	return columnUnknown
+}
+
+//按数据库类型名推断数值类别，兼容PostgreSQL和MySQL的常见类型名
+//只匹配完整的类型名（忽略长度精度和UNSIGNED），INTERVAL、POINT、_INT4（数组）等不是数值类型
+func parseColumnKind(name string) columnKind {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if idx := strings.Index(name, "("); idx >= 0 {
+		name = strings.TrimSpace(name[:idx])
+	}
+	name = strings.TrimPrefix(name, "UNSIGNED ")
+	name = strings.TrimSuffix(name, " UNSIGNED")
+	switch name {
+	case "DECIMAL", "NUMERIC":
+		return columnDecimal
+	case "INT", "INTEGER", "INT2", "INT4", "INT8", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT",
+		"SERIAL", "SERIAL2", "SERIAL4", "SERIAL8", "SMALLSERIAL", "BIGSERIAL":
+		return columnInteger
+	case "FLOAT", "FLOAT4", "FLOAT8", "DOUBLE", "DOUBLE PRECISION", "REAL":
+		return columnFloat
+	}
+	return columnUnknown
+}
+
+//按列类别规范驱动返回的值：文本协议中数值列的[]byte转为int64、uint64或float64，
+//小数列转为字符串以保留精度，其他值原样返回
+func normalizeValue(src interface{}, kind columnKind) interface{} {
+	var str string
+	switch v := src.(type) {
+	case []byte:
+		str = string(v)
+	case string:
+		str = v
+	default:
+		return src
+	}
+	switch kind {
+	case columnDecimal:
+		return str
+	case columnInteger:
+		if intVal, err := strconv.ParseInt(str, 10, 64); nil == err {
+			return intVal
+		}
+		if uintVal, err := strconv.ParseUint(str, 10, 64); nil == err {
+			return uintVal
+		}
+	case columnFloat:
+		if floatVal, err := strconv.ParseFloat(str, 64); nil == err {
+			return floatVal
+		}
+	}
+	return src
+}
+
+//有符号整数设值，检查范围和符号
+func setInt(target reflect.Value, v int64) error {
+	switch kind := target.Kind(); {
+	case isSignedInteger(kind):
+		if target.OverflowInt(v) {
+			return ErrValueOverflow
+		}
+		target.SetInt(v)
+	case isUnsignedInteger(kind):
+		if v < 0 {
+			return ErrValueSign
+		}
+		return setUint(target, uint64(v))
+	case isFloat(kind):
+		target.SetFloat(float64(v))
+	case kind == reflect.Bool:
+		target.SetBool(v != 0)
+	case kind == reflect.String:
+		target.SetString(strconv.FormatInt(v, 10))
+	default:
+		return ErrUnSupportTypeConvert
+	}
+	return nil
+}
+
+//无符号整数设值，检查范围
+func setUint(target reflect.Value, v uint64) error {
+	switch kind := target.Kind(); {
+	case isSignedInteger(kind):
+		if v > math.MaxInt64 || target.OverflowInt(int64(v)) {
+			return ErrValueOverflow
+		}
+		target.SetInt(int64(v))
+	case isUnsignedInteger(kind):
+		if target.OverflowUint(v) {
+			return ErrValueOverflow
+		}
+		target.SetUint(v)
+	case isFloat(kind):
+		target.SetFloat(float64(v))
+	case kind == reflect.Bool:
+		target.SetBool(v != 0)
+	case kind == reflect.String:
+		target.SetString(strconv.FormatUint(v, 10))
+	default:
+		return ErrUnSupportTypeConvert
+	}
+	return nil
+}
+
+//浮点数设值，转换到整数时需为整数值且在范围内
+func setFloat(target reflect.Value, v float64) error {
+	switch kind := target.Kind(); {
+	case isFloat(kind):
+		if target.OverflowFloat(v) {
+			return ErrValueOverflow
+		}
+		target.SetFloat(v)
+	case isInteger(kind):
+		if v != math.Trunc(v) {
+			return ErrPrecisionLoss
+		}
+		if v < 0 {
+			if v < math.MinInt64 {
+				return ErrValueOverflow
+			}
+			return setInt(target, int64(v))
+		}
+		if v >= math.MaxUint64 {
+			return ErrValueOverflow
+		}
+		return setUint(target, uint64(v))
+	case kind == reflect.String:
+		target.SetString(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return ErrUnSupportTypeConvert
+	}
+	return nil
+}
+
+//数值字符串设值到整数，小数需为整数值，如12.00
+func setIntString(target reflect.Value, str string) error {
+	if intVal, err := strconv.ParseInt(str, 10, 64); nil == err {
+		return setInt(target, intVal)
+	}
+	if uintVal, err := strconv.ParseUint(str, 10, 64); nil == err {
+		return setUint(target, uintVal)
+	}
+	rat, ok := new(big.Rat).SetString(str)
+	if !ok {
+		return ErrConvertValue
+	}
+	if !rat.IsInt() {
+		return ErrPrecisionLoss
+	}
+	if num := rat.Num(); num.IsInt64() {
+		return setInt(target, num.Int64())
+	} else if num.IsUint64() {
+		return setUint(target, num.Uint64())
+	}
+	return ErrValueOverflow
+}
+
+//数值字符串设值到浮点数，超出范围时报错，精度按浮点数就近舍入
+func setFloatString(target reflect.Value, str string) error {
+	floatVal, err := strconv.ParseFloat(str, 64)
+	if nil != err {
+		if errors.Is(err, strconv.ErrRange) {
+			return ErrValueOverflow
+		}
+		return ErrConvertValue
+	}
+	return setFloat(target, floatVal)
+}
+
+//转换到big.Rat，支持数值字符串、整数和浮点数，字符串保持精确值
+func convertRat(src interface{}, target reflect.Value) error {
+	rat := new(big.Rat)
+	switch v := src.(type) {
+	case []byte:
+		if _, ok := rat.SetString(string(v)); !ok {
+			return ErrConvertValue
+		}
+	case string:
+		if _, ok := rat.SetString(v); !ok {
+			return ErrConvertValue
+		}
+	case int64:
+		rat.SetInt64(v)
+	case uint64:
+		rat.SetUint64(v)
+	case float64:
+		if nil == rat.SetFloat64(v) {
+			return ErrConvertValue
+		}
+	default:
+		return ErrUnSupportTypeConvert
+	}
+	target.Set(reflect.ValueOf(rat).Elem())
+	return nil
+}
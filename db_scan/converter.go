@@ -0,0 +1,61 @@
+package db_scan
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+//时间格式，如`pg:"birthday,format=2006-01-02"`，解析字符串和格式化为字符串时使用
+//format需为最后一个选项，之后的逗号视为格式的一部分，如format=Mon, 02 Jan 2006
+const TagOptionFormat = "format"
+
+type (
+	//自定义转换，src不为nil，dst可设值
+	ConvertFunc func(src interface{}, dst reflect.Value) error
+
+	converterKey struct {
+		src reflect.Type
+		dst reflect.Type
+	}
+)
+
+var (
+	converterMu    sync.Mutex
+	converters     atomic.Value //map[converterKey]ConvertFunc，写时复制
+	converterCount int32
+)
+
+//注册srcType到dstType的转换，优先于内置的转换规则，重复注册时覆盖
+//srcType为nil时匹配任意来源类型，用于如net.IP这类可能来自string或[]byte的目标类型
+//应在初始化阶段注册，fn为nil时取消注册
+func RegisterConverter(srcType, dstType reflect.Type, fn ConvertFunc) {
+	converterMu.Lock()
+	defer converterMu.Unlock()
+	old, _ := converters.Load().(map[converterKey]ConvertFunc)
+	updated := make(map[converterKey]ConvertFunc, len(old)+1)
+	for key, value := range old {
+		updated[key] = value
+	}
+	key := converterKey{src: srcType, dst: dstType}
+	if nil == fn {
+		delete(updated, key)
+	} else {
+		updated[key] = fn
+	}
+	converters.Store(updated)
+	atomic.StoreInt32(&converterCount, int32(len(updated)))
+}
+
+//查找注册的转换，精确匹配优先于任意来源类型
+func lookupConverter(srcType, dstType reflect.Type) (ConvertFunc, bool) {
+	if atomic.LoadInt32(&converterCount) == 0 {
+		return nil, false
+	}
+	registered := converters.Load().(map[converterKey]ConvertFunc)
+	if fn, ok := registered[converterKey{src: srcType, dst: dstType}]; ok {
+		return fn, true
+	}
+	fn, ok := registered[converterKey{dst: dstType}]
+	return fn, ok
+}
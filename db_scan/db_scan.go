@@ -1,6 +1,7 @@
 package db_scan
 
 import (
+	"database/sql"
 	"errors"
 	"reflect"
 	"strconv"
@@ -12,15 +13,27 @@ var (
 	ErrConvertValue         = errors.New("值类型转换失败")
 	ErrUnSupportTypeConvert = errors.New("暂不支持的类型转换")
 	ErrSliceToString        = errors.New("slice转string失败")
-	ErrEmptyResult          = errors.New("结果为空")
 )
 
+//没有数据，满足errors.Is(err, sql.ErrNoRows)
+var ErrEmptyResult error = emptyResultError{}
+
 const (
 	DefaultTagName    = "pg"                  //默认标签名称
 	DefaultTimeFormat = "2006-01-02 15:04:05" //默认时间格式
 )
 
+//字符串转time.Time时依次尝试的格式
+var timeParseFormats = []string{
+	DefaultTimeFormat,
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
 //database/sql的rows抽象接口
+//Scan的dest可能是*interface{}，也可能是sql.Scanner，与*sql.Rows一致
+//同时实现Err() error时，扫描结束后会检查迭代中的错误
 type IRows interface {
 	Close() error
 	Columns() ([]string, error)
@@ -43,6 +56,14 @@ func getPtrObjectValue(obj interface{}) reflect.Value {
 	return reflect.ValueOf(obj).Elem()
 }
 
+//多级指针解引用
+func indirectValue(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	return value
+}
+
 //获取指针对象的类型
 func getPtrObjectType(obj interface{}) reflect.Type {
 	return reflect.TypeOf(obj).Elem()
@@ -64,26 +85,67 @@ func isUnsignedInteger(k reflect.Kind) bool {
 	return k >= reflect.Uint && k <= reflect.Uintptr
 }
 
-//数据集扫描
+var timeType = reflect.TypeOf(time.Time{})
+
+//[]byte及以其为底层类型的类型
+func isBytes(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+//数据集扫描，target为指向结构体、结构体指针或它们的切片的指针
+//结果集逐行直接扫描到字段中，不会先转换为map，使用默认扫描器
 func Scan(rows IRows, target interface{}) error {
-	if nil == target || getObjectValue(target).IsNil() || getObjectType(target).Kind() != reflect.Ptr {
-		return ErrTargetNotSettable
-	}
-	datas, err := ExtraDatasFromRows(rows)
-	if nil != err {
-		return err
+	return defaultScanner.Scan(rows, target)
+}
+
+//多结果集处理，没有数据时不修改target
+//包含一对多关联时，主键相同的行即使不连续也会合并
+func (s *Scanner) scanSlice(rows IRows, valueObj reflect.Value) error {
+	var valueSliceObj reflect.Value
+	var mapping *structMapping
+	if structType := derefType(valueObj.Type().Elem()); structType.Kind() == reflect.Struct {
+		mapping = s.getStructMapping(structType)
 	}
-	switch getPtrObjectType(target).Kind() {
-	case reflect.Slice:
-		if nil == datas {
-			return nil
+	positions := make(map[interface{}]int)
+	err := s.scanRows(rows, valueObj.Type().Elem(), func(value reflect.Value) error {
+		if !valueSliceObj.IsValid() {
+			valueSliceObj = reflect.MakeSlice(valueObj.Type(), 0, 0)
 		}
-		err = multiResults(datas, target)
-	default:
-		if nil == datas {
-			return ErrEmptyResult
+		if nil != mapping && len(mapping.relations) > 0 {
+			structValue := indirectValue(value)
+			key := pkKey(structValue, mapping.pk)
+			if pos, ok := positions[key]; ok {
+				mapping.merge(indirectValue(valueSliceObj.Index(pos)), structValue)
+				return nil
+			}
+			positions[key] = valueSliceObj.Len()
 		}
-		err = singleResult(datas[0], target)
+		valueSliceObj = reflect.Append(valueSliceObj, value)
+		return nil
+	})
+	if nil == err && valueSliceObj.IsValid() {
+		valueObj.Set(valueSliceObj)
+	}
+	return err
+}
+
+//单一结果处理，只扫描第一行
+func (s *Scanner) scanSingle(rows IRows, valueObj reflect.Value) error {
+	found := false
+	var err error
+	if valueObj.Kind() == reflect.Ptr {
+		//指针目标分配新的对象
+		err = s.scanRows(rows, valueObj.Type(), func(value reflect.Value) error {
+			valueObj.Set(value)
+			found = true
+			return ErrStopScan
+		})
+	} else {
+		//结构体目标直接写入，保留未映射字段的原值
+		found, err = s.scanFirst(rows, valueObj)
+	}
+	if nil == err && !found {
+		return ErrEmptyResult
 	}
 	return err
 }
@@ -94,6 +156,7 @@ func ExtraDatasFromRows(rows IRows) ([]map[string]interface{}, error) {
 	if nil != err {
 		return nil, err
 	}
+	kinds := columnKinds(rows)
 	length := len(columns)
 	values := make([]interface{}, length)
 	for i := 0; i < length; i++ {
@@ -107,7 +170,7 @@ func ExtraDatasFromRows(rows IRows) ([]map[string]interface{}, error) {
 		}
 		mp := make(map[string]interface{})
 		for idx, name := range columns {
-			mp[name] = *(values[idx].(*interface{}))
+			mp[name] = normalizeValue(*(values[idx].(*interface{})), kindAt(kinds, idx))
 		}
 		result = append(result, mp)
 	}
@@ -199,84 +262,171 @@ func directSet(sourceVal interface{}, rTargetVal reflect.Value) bool {
 
 //map自动数据格式转换
 func valueConvert(sourceVal interface{}, rTargetVal reflect.Value) error {
+	return valueConvertFormat(sourceVal, rTargetVal, "")
+}
+
+//按指定的时间格式转换，format为空时：解析依次尝试timeParseFormats，格式化使用DefaultTimeFormat
+func valueConvertFormat(sourceVal interface{}, rTargetVal reflect.Value, format string) error {
 
 	sourceType := reflect.TypeOf(sourceVal)
 	if nil == sourceType {
-		return nil
+		return handleConvertNull(rTargetVal)
 	}
 	targetType := rTargetVal.Type()
 
+	//注册的转换优先于内置规则
+	if fn, ok := lookupConverter(sourceType, targetType); ok {
+		return fn(sourceVal, rTargetVal)
+	}
+
 	if directSet(sourceVal, rTargetVal) {
 		return nil
 	}
 
-	switch assertT := sourceVal.(type) {
-	case time.Time:
-		return handleConvertTime(assertT, sourceType, &rTargetVal)
+	//字段自身实现了sql.Scanner，如sql.NullString、uuid.UUID
+	if scanner, ok := asScanner(rTargetVal); ok {
+		return scanner.Scan(sourceVal)
 	}
 
-	switch sourceType.Kind() {
-	case reflect.Slice:
-		return handleConvertMapSliceToField(sourceVal, &rTargetVal)
-	case reflect.Int64:
-		if isSignedInteger(targetType.Kind()) {
-			rTargetVal.SetInt(sourceVal.(int64))
-		} else if isUnsignedInteger(targetType.Kind()) {
-			rTargetVal.SetUint(uint64(sourceVal.(int64)))
-		}
-	case reflect.Float32:
-		if isFloat(targetType.Kind()) {
-			rTargetVal.SetFloat(float64(sourceVal.(float32)))
-		}
-	case reflect.Float64:
-		if isFloat(targetType.Kind()) {
-			rTargetVal.SetFloat(sourceVal.(float64))
+	//指针字段分配新的对象后再转换
+	if targetType.Kind() == reflect.Ptr {
+		return handleConvertPtr(sourceVal, format, &rTargetVal)
+	}
+
+	//小数保持精确值
+	if targetType == ratType {
+		return convertRat(sourceVal, rTargetVal)
+	}
+
+	switch assertT := sourceVal.(type) {
+	case time.Time:
+		return handleConvertTime(assertT, format, &rTargetVal)
+	case string:
+		return handleConvertString(assertT, format, &rTargetVal)
+	case bool:
+		if targetType.Kind() == reflect.Bool {
+			rTargetVal.SetBool(assertT)
+			return nil
 		}
-	default:
 		return ErrConvertValue
 	}
+
+	//数值类型检查范围、符号和精度
+	switch sourceKind := sourceType.Kind(); {
+	case sourceKind == reflect.Slice:
+		return handleConvertMapSliceToField(sourceVal, format, &rTargetVal)
+	case isSignedInteger(sourceKind):
+		return setInt(rTargetVal, reflect.ValueOf(sourceVal).Int())
+	case isUnsignedInteger(sourceKind):
+		return setUint(rTargetVal, reflect.ValueOf(sourceVal).Uint())
+	case isFloat(sourceKind):
+		return setFloat(rTargetVal, reflect.ValueOf(sourceVal).Float())
+	}
+	return ErrConvertValue
+}
+
+//获取字段实现的sql.Scanner
+func asScanner(rTargetVal reflect.Value) (sql.Scanner, bool) {
+	if !rTargetVal.CanAddr() {
+		return nil, false
+	}
+	scanner, ok := rTargetVal.Addr().Interface().(sql.Scanner)
+	return scanner, ok
+}
+
+//NULL值：Scanner交给其自身处理，指针等可为nil的字段置空，其他字段保持原值
+func handleConvertNull(rTargetVal reflect.Value) error {
+	if scanner, ok := asScanner(rTargetVal); ok {
+		return scanner.Scan(nil)
+	}
+	switch rTargetVal.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		rTargetVal.Set(reflect.Zero(rTargetVal.Type()))
+	}
 	return nil
 }
 
-//slice的值转换
-func handleConvertMapSliceToField(mapValue interface{}, rTargetValPtr *reflect.Value) error {
-	rTargetValKind := (*rTargetValPtr).Type().Kind()
+//指针的值转换
+func handleConvertPtr(sourceVal interface{}, format string, rTargetValPtr *reflect.Value) error {
+	elem := reflect.New(rTargetValPtr.Type().Elem())
+	if err := valueConvertFormat(sourceVal, elem.Elem(), format); err != nil {
+		return err
+	}
+	rTargetValPtr.Set(elem)
+	return nil
+}
 
+//slice的值转换
+func handleConvertMapSliceToField(mapValue interface{}, format string, rTargetValPtr *reflect.Value) error {
 	mapValueSlice, ok := mapValue.([]byte)
 	if !ok {
 		return ErrSliceToString
 	}
-	mapValueStr := string(mapValueSlice)
+	//[]byte类型的字段，如json.RawMessage，复制一份避免引用驱动的缓冲区
+	if isBytes(rTargetValPtr.Type()) {
+		rTargetValPtr.SetBytes(append([]byte(nil), mapValueSlice...))
+		return nil
+	}
+	return handleConvertString(string(mapValueSlice), format, rTargetValPtr)
+}
+
+//字符串的值转换
+func handleConvertString(mapValueStr string, format string, rTargetValPtr *reflect.Value) error {
+	rTargetValKind := (*rTargetValPtr).Type().Kind()
+
 	switch {
 	case rTargetValKind == reflect.String:
 		rTargetValPtr.SetString(mapValueStr)
-	case isSignedInteger(rTargetValKind):
-		intVal, err := strconv.ParseInt(mapValueStr, 10, 64)
+	case isBytes(rTargetValPtr.Type()):
+		rTargetValPtr.SetBytes([]byte(mapValueStr))
+	case rTargetValPtr.Type() == timeType:
+		timeVal, err := parseTime(mapValueStr, format)
 		if nil != err {
 			return ErrConvertValue
 		}
-		rTargetValPtr.SetInt(intVal)
-	case isUnsignedInteger(rTargetValKind):
-		uintVal, err := strconv.ParseUint(mapValueStr, 10, 64)
+		rTargetValPtr.Set(reflect.ValueOf(timeVal))
+	case rTargetValKind == reflect.Bool:
+		boolVal, err := strconv.ParseBool(mapValueStr)
 		if nil != err {
 			return ErrConvertValue
 		}
-		rTargetValPtr.SetUint(uintVal)
+		rTargetValPtr.SetBool(boolVal)
+	case isInteger(rTargetValKind):
+		return setIntString(*rTargetValPtr, mapValueStr)
 	case isFloat(rTargetValKind):
-		floatVal, err := strconv.ParseFloat(mapValueStr, 64)
-		if nil != err {
-			return ErrConvertValue
-		}
-		rTargetValPtr.SetFloat(floatVal)
+		return setFloatString(*rTargetValPtr, mapValueStr)
+	case rTargetValKind == reflect.Slice:
+		return convertPGArray(mapValueStr, format, *rTargetValPtr)
+	case rTargetValKind == reflect.Map && rTargetValPtr.Type().Key().Kind() == reflect.String:
+		return convertPGHstore(mapValueStr, *rTargetValPtr)
 	default:
 		return ErrUnSupportTypeConvert
 	}
 	return nil
 }
 
-func handleConvertTime(assertT time.Time, mvt reflect.Type, valueI *reflect.Value) error {
+//format不为空时只按format解析
+func parseTime(str string, format string) (time.Time, error) {
+	if format != "" {
+		return time.Parse(format, str)
+	}
+	var err error
+	for _, format := range timeParseFormats {
+		var timeVal time.Time
+		if timeVal, err = time.Parse(format, str); nil == err {
+			return timeVal, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+//时间转字符串，format为空时使用DefaultTimeFormat
+func handleConvertTime(assertT time.Time, format string, valueI *reflect.Value) error {
 	if (*valueI).Type().Kind() == reflect.String {
-		str := assertT.Format(DefaultTimeFormat)
+		if format == "" {
+			format = DefaultTimeFormat
+		}
+		str := assertT.Format(format)
 		valueI.SetString(str)
 		return nil
 	}
@@ -1,8 +1,17 @@
 package db_scan
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tevid/go-tevid-utils/db_scan/dbtest"
 	. "github.com/tevid/gohamcrest"
+	"net"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDBScanExtraSigleData(t *testing.T) {
@@ -76,3 +85,601 @@ func TestDBScanExtraMultiData(t *testing.T) {
 		}
 	}
 }
+
+type testUpperString string
+
+//自定义的sql.Scanner
+func (s *testUpperString) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		*s = testUpperString(strings.ToUpper(string(v)))
+	case string:
+		*s = testUpperString(strings.ToUpper(v))
+	case nil:
+		*s = ""
+	default:
+		return ErrConvertValue
+	}
+	return nil
+}
+
+func TestDBScanScannerAndPointerFields(t *testing.T) {
+	type Person struct {
+		Name    sql.NullString   `pg:"name"`
+		Nick    sql.NullString   `pg:"nick"`
+		Age     *int             `pg:"age"`
+		Score   *float64         `pg:"score"`
+		Code    testUpperString  `pg:"code"`
+		Manager *testUpperString `pg:"manager"`
+	}
+	p := Person{Score: new(float64)}
+	err := singleResult(map[string]interface{}{
+		"name":    []byte("tencent"),
+		"nick":    nil,
+		"age":     int64(20),
+		"score":   nil,
+		"code":    []byte("abc"),
+		"manager": "xyz",
+	}, &p)
+	Assert(t, err, NilVal())
+	Assert(t, p.Name, Equal(sql.NullString{String: "tencent", Valid: true}))
+	Assert(t, p.Nick.Valid, Equal(false))
+	Assert(t, *p.Age, Equal(20))
+	Assert(t, p.Score, NilVal())
+	Assert(t, p.Code, Equal(testUpperString("ABC")))
+	Assert(t, *p.Manager, Equal(testUpperString("XYZ")))
+}
+
+func TestDBScanBoolTimeAndRawMessage(t *testing.T) {
+	type Record struct {
+		Enabled  bool            `pg:"enabled"`
+		Deleted  bool            `pg:"deleted"`
+		Visible  bool            `pg:"visible"`
+		Created  time.Time       `pg:"created"`
+		Updated  *time.Time      `pg:"updated"`
+		Extra    json.RawMessage `pg:"extra"`
+		ExtraStr json.RawMessage `pg:"extra_str"`
+	}
+	var r Record
+	raw := []byte(`{"a":1}`)
+	err := singleResult(map[string]interface{}{
+		"enabled":   int64(1),
+		"deleted":   []byte("false"),
+		"visible":   true,
+		"created":   []byte("2020-01-02 03:04:05"),
+		"updated":   "2020-01-02T03:04:05Z",
+		"extra":     raw,
+		"extra_str": `[1,2]`,
+	}, &r)
+	Assert(t, err, NilVal())
+	Assert(t, r.Enabled, Equal(true))
+	Assert(t, r.Deleted, Equal(false))
+	Assert(t, r.Visible, Equal(true))
+	Assert(t, r.Created, Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+	Assert(t, r.Updated.Equal(r.Created), Equal(true))
+	Assert(t, string(r.Extra), Equal(`{"a":1}`))
+	Assert(t, string(r.ExtraStr), Equal(`[1,2]`))
+
+	err = singleResult(map[string]interface{}{"created": "not a time"}, &r)
+	Assert(t, err, Equal(ErrConvertValue))
+}
+
+//测试用的内存结果集
+func newTestRows(columns []string, rows ...[]interface{}) *dbtest.Rows {
+	return dbtest.NewRows(columns, rows...)
+}
+
+type testUser struct {
+	ID   int64  `pg:"id"`
+	Name string `pg:"name"`
+	Note string
+}
+
+func TestScan(t *testing.T) {
+	var users []testUser
+	err := Scan(newTestRows([]string{"id", "name", "other"},
+		[]interface{}{int64(1), []byte("a"), "x"},
+		[]interface{}{int64(2), []byte("b"), "y"},
+	), &users)
+	Assert(t, err, NilVal())
+	Assert(t, users, Equal([]testUser{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}))
+
+	var ptrs []*testUser
+	err = Scan(newTestRows([]string{"id"}, []interface{}{int64(3)}), &ptrs)
+	Assert(t, err, NilVal())
+	Assert(t, ptrs[0].ID, Equal(int64(3)))
+
+	//单一结果保留未映射字段
+	user := testUser{Note: "keep"}
+	err = Scan(newTestRows([]string{"id", "name"}, []interface{}{int64(4), "d"}), &user)
+	Assert(t, err, NilVal())
+	Assert(t, user, Equal(testUser{ID: 4, Name: "d", Note: "keep"}))
+
+	var userPtr *testUser
+	err = Scan(newTestRows([]string{"id"}, []interface{}{int64(5)}), &userPtr)
+	Assert(t, err, NilVal())
+	Assert(t, userPtr.ID, Equal(int64(5)))
+
+	err = Scan(newTestRows([]string{"id"}), &user)
+	Assert(t, err, Equal(ErrEmptyResult))
+	err = Scan(newTestRows([]string{"id"}), &users)
+	Assert(t, err, NilVal())
+	Assert(t, len(users), Equal(2))
+
+	err = Scan(newTestRows([]string{"id"}), user)
+	Assert(t, err, Equal(ErrTargetNotSettable))
+}
+
+func TestScanEach(t *testing.T) {
+	rows := newTestRows([]string{"id", "name"},
+		[]interface{}{int64(1), []byte("a")},
+		[]interface{}{int64(2), []byte("b")},
+		[]interface{}{int64(3), []byte("c")},
+	)
+	var names []string
+	err := ScanEach(rows, func(u *testUser) error {
+		names = append(names, u.Name)
+		if u.ID == 2 {
+			return ErrStopScan
+		}
+		return nil
+	})
+	Assert(t, err, NilVal())
+	Assert(t, names, Equal([]string{"a", "b"}))
+
+	err = ScanEach(newTestRows([]string{"id"}, []interface{}{"x"}), func(u *testUser) error {
+		return nil
+	})
+	Assert(t, errors.Is(err, ErrConvertValue), Equal(true))
+	Assert(t, strings.Contains(err.Error(), "id"), Equal(true))
+}
+
+func TestIterator(t *testing.T) {
+	rows := newTestRows([]string{"id", "name"},
+		[]interface{}{int64(1), []byte("a")},
+		[]interface{}{int64(2), []byte("b")},
+	)
+	it := NewIterator[testUser](rows)
+	var ids []int64
+	for it.Next() {
+		ids = append(ids, it.Value().ID)
+	}
+	Assert(t, it.Err(), NilVal())
+	Assert(t, ids, Equal([]int64{1, 2}))
+	Assert(t, it.Close(), NilVal())
+	Assert(t, rows.Closed(), Equal(true))
+}
+
+type testAudit struct {
+	CreatedAt string `pg:"created_at"`
+	UpdatedAt string `pg:"updated_at"`
+}
+
+type testBook struct {
+	ID    int64  `pg:"id,pk"`
+	Title string `pg:"title"`
+}
+
+type testAuthor struct {
+	testAudit
+	ID       int64       `pg:"id,pk"`
+	Name     string      `pg:"name"`
+	Profile  *testBook   `pg:"fav_,prefix"`
+	Books    []testBook  `pg:"book_,prefix"`
+	Comments []*testBook `pg:"comment_,prefix"`
+}
+
+func TestScanEmbeddedAndNested(t *testing.T) {
+	//嵌入的指针需为导出类型才能分配
+	type Audit testAudit
+	type Post struct {
+		*Audit
+		ID     int64    `pg:"id"`
+		Author testBook `pg:"author_,prefix"`
+	}
+	var posts []Post
+	err := Scan(newTestRows([]string{"id", "created_at", "author_id", "author_title"},
+		[]interface{}{int64(1), "2020", int64(9), "tom"},
+		[]interface{}{int64(2), nil, nil, nil},
+	), &posts)
+	Assert(t, err, NilVal())
+	Assert(t, posts[0].CreatedAt, Equal("2020"))
+	Assert(t, posts[0].Author, Equal(testBook{ID: 9, Title: "tom"}))
+	//NULL不为嵌入的指针分配对象
+	Assert(t, posts[1].Audit, NilVal())
+}
+
+func TestScanOneToMany(t *testing.T) {
+	columns := []string{"id", "name", "created_at", "fav_id", "book_id", "book_title", "comment_id", "comment_title"}
+	newRows := func() *dbtest.Rows {
+		return newTestRows(columns,
+			[]interface{}{int64(1), "a", "2020", nil, int64(10), "b1", int64(100), "c1"},
+			[]interface{}{int64(1), "a", "2020", nil, int64(11), "b2", int64(100), "c1"},
+			[]interface{}{int64(2), "b", "2021", int64(5), nil, nil, nil, nil},
+			[]interface{}{int64(1), "a", "2020", nil, int64(12), "b3", int64(101), "c2"},
+		)
+	}
+
+	var authors []testAuthor
+	err := Scan(newRows(), &authors)
+	Assert(t, err, NilVal())
+	Assert(t, len(authors), Equal(2))
+	Assert(t, authors[0].CreatedAt, Equal("2020"))
+	Assert(t, authors[0].Books, Equal([]testBook{{10, "b1"}, {11, "b2"}, {12, "b3"}}))
+	Assert(t, len(authors[0].Comments), Equal(2))
+	Assert(t, *authors[0].Comments[1], Equal(testBook{101, "c2"}))
+	Assert(t, authors[0].Profile, NilVal())
+	Assert(t, authors[1].Profile.ID, Equal(int64(5)))
+	Assert(t, len(authors[1].Books), Equal(0))
+
+	//ScanEach只合并连续的行
+	var counts []int
+	err = ScanEach(newRows(), func(a *testAuthor) error {
+		counts = append(counts, len(a.Books))
+		return nil
+	})
+	Assert(t, err, NilVal())
+	Assert(t, counts, Equal([]int{2, 0, 1}))
+
+	var author testAuthor
+	err = Scan(newRows(), &author)
+	Assert(t, err, NilVal())
+	Assert(t, len(author.Books), Equal(2))
+
+	type NoPK struct {
+		Books []testBook `pg:"book_,prefix"`
+	}
+	var noPK []NoPK
+	Assert(t, Scan(newRows(), &noPK), Equal(ErrNoPrimaryKey))
+}
+
+func TestToSnakeCase(t *testing.T) {
+	Assert(t, ToSnakeCase("UserID"), Equal("user_id"))
+	Assert(t, ToSnakeCase("HTTPServer"), Equal("http_server"))
+	Assert(t, ToSnakeCase("CreatedAt2"), Equal("created_at2"))
+	Assert(t, ToSnakeCase("name"), Equal("name"))
+}
+
+func TestScanner(t *testing.T) {
+	type Account struct {
+		ID       int64  `db:"id"`
+		UserName string //蛇形映射为user_name
+		Secret   string `db:"-"`
+		Email    string `pg:"mail"`
+	}
+	s := NewScanner(WithTagName("db"), WithSnakeCase(), WithCaseInsensitive())
+	var accounts []Account
+	err := s.Scan(newTestRows([]string{"ID", "USER_NAME", "secret", "mail"},
+		[]interface{}{int64(1), "tom", "x", "a@b"},
+	), &accounts)
+	Assert(t, err, NilVal())
+	Assert(t, accounts, Equal([]Account{{ID: 1, UserName: "tom"}}))
+
+	var names []string
+	err = ScanEachWith(s, newTestRows([]string{"user_name"}, []interface{}{"a"}, []interface{}{"b"}), func(a *Account) error {
+		names = append(names, a.UserName)
+		return nil
+	})
+	Assert(t, err, NilVal())
+	Assert(t, names, Equal([]string{"a", "b"}))
+
+	it := NewIteratorWith[Account](s, newTestRows([]string{"id"}, []interface{}{int64(7)}))
+	Assert(t, it.Next(), Equal(true))
+	Assert(t, it.Value().ID, Equal(int64(7)))
+
+	//默认扫描器区分大小写，忽略没有标签的字段
+	var user testUser
+	err = Scan(newTestRows([]string{"ID", "name", "note"}, []interface{}{int64(1), "a", "b"}), &user)
+	Assert(t, err, NilVal())
+	Assert(t, user, Equal(testUser{Name: "a"}))
+}
+
+func TestScannerStrict(t *testing.T) {
+	s := NewScanner(WithStrict())
+	var users []testUser
+	err := s.Scan(newTestRows([]string{"id", "name", "age"}, []interface{}{int64(1), "a", int64(3)}), &users)
+	Assert(t, errors.Is(err, ErrUnknownColumn), Equal(true))
+	Assert(t, strings.Contains(err.Error(), "age"), Equal(true))
+
+	err = s.Scan(newTestRows([]string{"id"}, []interface{}{int64(1)}), &users)
+	Assert(t, errors.Is(err, ErrMissingColumn), Equal(true))
+	Assert(t, strings.Contains(err.Error(), "name"), Equal(true))
+
+	err = s.Scan(newTestRows([]string{"name", "id"}, []interface{}{"a", int64(1)}), &users)
+	Assert(t, err, NilVal())
+	Assert(t, users, Equal([]testUser{{ID: 1, Name: "a"}}))
+}
+
+func TestScanScalar(t *testing.T) {
+	var count int
+	err := Scan(newTestRows([]string{"count"}, []interface{}{int64(3)}), &count)
+	Assert(t, err, NilVal())
+	Assert(t, count, Equal(3))
+
+	var name *string
+	err = Scan(newTestRows([]string{"name"}, []interface{}{[]byte("tom")}), &name)
+	Assert(t, err, NilVal())
+	Assert(t, *name, Equal("tom"))
+
+	var nullName sql.NullString
+	err = Scan(newTestRows([]string{"name"}, []interface{}{nil}), &nullName)
+	Assert(t, err, NilVal())
+	Assert(t, nullName.Valid, Equal(false))
+
+	Assert(t, Scan(newTestRows([]string{"count"}), &count), Equal(ErrEmptyResult))
+	err = Scan(newTestRows([]string{"id", "name"}, []interface{}{int64(1), "a"}), &count)
+	Assert(t, errors.Is(err, ErrColumnCount), Equal(true))
+
+	var names []string
+	err = Scan(newTestRows([]string{"name"}, []interface{}{"a"}, []interface{}{[]byte("b")}), &names)
+	Assert(t, err, NilVal())
+	Assert(t, names, Equal([]string{"a", "b"}))
+	err = Scan(newTestRows([]string{"id", "name"}, []interface{}{int64(1), "a"}), &names)
+	Assert(t, errors.Is(err, ErrColumnCount), Equal(true))
+}
+
+func TestScanMap(t *testing.T) {
+	var row map[string]interface{}
+	err := Scan(newTestRows([]string{"id", "name"}, []interface{}{int64(1), nil}), &row)
+	Assert(t, err, NilVal())
+	Assert(t, row, Equal(map[string]interface{}{"id": int64(1), "name": nil}))
+
+	var rowList []map[string]interface{}
+	err = Scan(newTestRows([]string{"id"}, []interface{}{int64(1)}, []interface{}{int64(2)}), &rowList)
+	Assert(t, err, NilVal())
+	Assert(t, len(rowList), Equal(2))
+	Assert(t, rowList[1]["id"], Equal(int64(2)))
+
+	var names map[int]string
+	err = Scan(newTestRows([]string{"id", "name"},
+		[]interface{}{int64(1), "a"},
+		[]interface{}{int64(2), []byte("b")},
+	), &names)
+	Assert(t, err, NilVal())
+	Assert(t, names, Equal(map[int]string{1: "a", 2: "b"}))
+
+	err = Scan(newTestRows([]string{"id"}, []interface{}{int64(1)}), &names)
+	Assert(t, errors.Is(err, ErrColumnCount), Equal(true))
+}
+
+func TestNamed(t *testing.T) {
+	arg := testAuthor{ID: 1, Name: "tom", Profile: nil}
+	query, args, err := Named("SELECT * FROM t WHERE id = :id AND name = ':id' AND note::text = :name OR fav = :fav_id", &arg)
+	Assert(t, err, NilVal())
+	Assert(t, query, Equal("SELECT * FROM t WHERE id = $1 AND name = ':id' AND note::text = $2 OR fav = $3"))
+	Assert(t, args, Equal([]interface{}{int64(1), "tom", nil}))
+
+	s := NewScanner(WithDialect(DialectMySQL))
+	query, args, err = s.Named("SELECT * FROM t WHERE id IN (:ids) AND data = :data", map[string]interface{}{
+		"ids":  []int{1, 2, 3},
+		"data": []byte("x"),
+	})
+	Assert(t, err, NilVal())
+	Assert(t, query, Equal("SELECT * FROM t WHERE id IN (?, ?, ?) AND data = ?"))
+	Assert(t, args, Equal([]interface{}{1, 2, 3, []byte("x")}))
+
+	_, _, err = Named("SELECT :missing", &arg)
+	Assert(t, errors.Is(err, ErrMissingParam), Equal(true))
+	_, _, err = Named("SELECT :ids", map[string]interface{}{"ids": []int{}})
+	Assert(t, errors.Is(err, ErrEmptyIn), Equal(true))
+	_, _, err = Named("SELECT :id", 1)
+	Assert(t, err, Equal(ErrParamSource))
+
+	//注释中的引号和参数不做处理
+	params := map[string]interface{}{"id": 1}
+	query, args, err = Named("SELECT 1 -- don't :x\nWHERE id = :id /* it's :y */ AND 1 = 1", params)
+	Assert(t, err, NilVal())
+	Assert(t, query, Equal("SELECT 1 -- don't :x\nWHERE id = $1 /* it's :y */ AND 1 = 1"))
+	Assert(t, args, Equal([]interface{}{1}))
+
+	//数组切片下标
+	query, args, err = Named("SELECT arr[1:2], arr[:3], arr[lo:hi] FROM t WHERE id = :id", params)
+	Assert(t, err, NilVal())
+	Assert(t, query, Equal("SELECT arr[1:2], arr[:3], arr[lo:hi] FROM t WHERE id = $1"))
+	Assert(t, args, Equal([]interface{}{1}))
+}
+
+func TestBulkInsert(t *testing.T) {
+	users := []*testUser{{ID: 1, Name: "a", Note: "x"}, {ID: 2, Name: "b"}}
+	query, args, err := BulkInsert("users", users)
+	Assert(t, err, NilVal())
+	Assert(t, query, Equal("INSERT INTO users (id, name) VALUES ($1, $2), ($3, $4)"))
+	Assert(t, args, Equal([]interface{}{int64(1), "a", int64(2), "b"}))
+
+	s := NewScanner(WithTagName("json"), WithDialect(DialectMySQL))
+	type Item struct {
+		Code  string `json:"code"`
+		Price int    `json:"price"`
+	}
+	query, args, err = s.BulkInsert("items", []Item{{"a", 1}})
+	Assert(t, err, NilVal())
+	Assert(t, query, Equal("INSERT INTO items (code, price) VALUES (?, ?)"))
+	Assert(t, args, Equal([]interface{}{"a", 1}))
+	Assert(t, s.Columns(reflect.TypeOf(Item{})), Equal([]string{"code", "price"}))
+
+	_, _, err = BulkInsert("users", []testUser{})
+	Assert(t, err, Equal(ErrEmptyInsert))
+}
+
+func TestValueConvertRange(t *testing.T) {
+	var u uint
+	var i32 int32
+	var i int
+	var f32 float32
+	var s string
+	Assert(t, valueConvert(int64(-1), reflect.ValueOf(&u).Elem()), Equal(ErrValueSign))
+	Assert(t, valueConvert(int64(1)<<40, reflect.ValueOf(&i32).Elem()), Equal(ErrValueOverflow))
+	Assert(t, valueConvert(1.5, reflect.ValueOf(&i).Elem()), Equal(ErrPrecisionLoss))
+	Assert(t, valueConvert(1e300, reflect.ValueOf(&f32).Elem()), Equal(ErrValueOverflow))
+	Assert(t, valueConvert("-3", reflect.ValueOf(&u).Elem()), Equal(ErrValueSign))
+	Assert(t, valueConvert("99999999999999999999", reflect.ValueOf(&i).Elem()), Equal(ErrValueOverflow))
+	Assert(t, valueConvert("12.00", reflect.ValueOf(&i).Elem()), NilVal())
+	Assert(t, i, Equal(12))
+	Assert(t, valueConvert(int64(7), reflect.ValueOf(&s).Elem()), NilVal())
+	Assert(t, s, Equal("7"))
+}
+
+func TestScanPGTypes(t *testing.T) {
+	type Profile struct {
+		Age  int      `json:"age"`
+		Tags []string `json:"tags"`
+	}
+	type Row struct {
+		Names    []string           `pg:"names"`
+		Scores   []*int             `pg:"scores"`
+		Matrix   [][]int            `pg:"matrix"`
+		Profile  *Profile           `pg:"profile,json"`
+		Extra    map[string]int     `pg:"extra,json"`
+		Attrs    map[string]string  `pg:"attrs"`
+		AttrPtrs map[string]*string `pg:"attr_ptrs"`
+	}
+	var rows []Row
+	err := Scan(newTestRows([]string{"names", "scores", "matrix", "profile", "extra", "attrs", "attr_ptrs"},
+		[]interface{}{
+			[]byte(`{a,"b c","d\"e",NULL}`),
+			"{1,NULL,3}",
+			"{{1,2},{3,4}}",
+			[]byte(`{"age":3,"tags":["x"]}`),
+			[]byte(`{"k":1}`),
+			`"a"=>"1", "b c"=>NULL`,
+			`"a"=>"1", "b c"=>NULL`,
+		},
+		[]interface{}{"{}", "[0:1]={5,6}", nil, nil, nil, "", nil},
+	), &rows)
+	Assert(t, err, NilVal())
+	Assert(t, rows[0].Names, Equal([]string{"a", "b c", `d"e`, ""}))
+	Assert(t, *rows[0].Scores[0], Equal(1))
+	Assert(t, rows[0].Scores[1], NilVal())
+	Assert(t, rows[0].Matrix, Equal([][]int{{1, 2}, {3, 4}}))
+	Assert(t, *rows[0].Profile, Equal(Profile{Age: 3, Tags: []string{"x"}}))
+	Assert(t, rows[0].Extra, Equal(map[string]int{"k": 1}))
+	Assert(t, rows[0].Attrs, Equal(map[string]string{"a": "1", "b c": ""}))
+	Assert(t, rows[0].AttrPtrs, Not(NilVal()))
+	Assert(t, len(rows[0].AttrPtrs), Equal(2))
+	Assert(t, *rows[0].AttrPtrs["a"], Equal("1"))
+	ptr, ok := rows[0].AttrPtrs["b c"]
+	Assert(t, ok, Equal(true))
+	Assert(t, ptr, NilVal())
+	Assert(t, len(rows[1].Names), Equal(0))
+	Assert(t, *rows[1].Scores[1], Equal(6))
+	Assert(t, rows[1].Profile, NilVal())
+	Assert(t, len(rows[1].Attrs), Equal(0))
+
+	var names []string
+	Assert(t, errors.Is(valueConvert("{a,b", reflect.ValueOf(&names).Elem()), ErrPGArray), Equal(true))
+	var attrs map[string]string
+	Assert(t, errors.Is(valueConvert(`"a"="b"`, reflect.ValueOf(&attrs).Elem()), ErrPGHstore), Equal(true))
+
+	//写入时json字段编码为JSON文本，扫描回来与原值一致
+	type Doc struct {
+		ID      int               `pg:"id"`
+		Profile *Profile          `pg:"profile,json"`
+		Meta    map[string]string `pg:"meta,json"`
+	}
+	docs := []Doc{{ID: 1, Profile: &Profile{Age: 3, Tags: []string{"x"}}, Meta: map[string]string{"a": "b"}}, {ID: 2}}
+	query, args, err := BulkInsert("docs", docs)
+	Assert(t, err, NilVal())
+	Assert(t, query, Equal("INSERT INTO docs (id, profile, meta) VALUES ($1, $2, $3), ($4, $5, $6)"))
+	Assert(t, args, Equal([]interface{}{1, `{"age":3,"tags":["x"]}`, `{"a":"b"}`, 2, nil, nil}))
+	_, namedArgs, err := Named("UPDATE docs SET meta = :meta WHERE id = :id", &docs[0])
+	Assert(t, err, NilVal())
+	Assert(t, namedArgs, Equal([]interface{}{`{"a":"b"}`, 1}))
+
+	var scanned []Doc
+	Assert(t, Scan(newTestRows([]string{"id", "profile", "meta"}, args[:3], args[3:]), &scanned), NilVal())
+	Assert(t, scanned, Equal(docs))
+}
+
+type testMoney int64
+
+type testStatus int
+
+func TestRegisterConverter(t *testing.T) {
+	RegisterConverter(reflect.TypeOf(""), reflect.TypeOf(testMoney(0)), func(src interface{}, dst reflect.Value) error {
+		var yuan, fen int64
+		if _, err := fmt.Sscanf(src.(string), "$%d.%d", &yuan, &fen); nil != err {
+			return err
+		}
+		dst.SetInt(yuan*100 + fen)
+		return nil
+	})
+	RegisterConverter(nil, reflect.TypeOf(net.IP{}), func(src interface{}, dst reflect.Value) error {
+		var str string
+		switch v := src.(type) {
+		case string:
+			str = v
+		case []byte:
+			str = string(v)
+		}
+		ip := net.ParseIP(str)
+		if nil == ip {
+			return ErrConvertValue
+		}
+		dst.Set(reflect.ValueOf(ip))
+		return nil
+	})
+	statuses := map[string]testStatus{"active": 1, "disabled": 2}
+	RegisterConverter(reflect.TypeOf([]byte(nil)), reflect.TypeOf(testStatus(0)), func(src interface{}, dst reflect.Value) error {
+		dst.SetInt(int64(statuses[string(src.([]byte))]))
+		return nil
+	})
+	defer func() {
+		RegisterConverter(reflect.TypeOf(""), reflect.TypeOf(testMoney(0)), nil)
+		RegisterConverter(nil, reflect.TypeOf(net.IP{}), nil)
+		RegisterConverter(reflect.TypeOf([]byte(nil)), reflect.TypeOf(testStatus(0)), nil)
+	}()
+
+	type Account struct {
+		Balance testMoney  `pg:"balance"`
+		Addr    net.IP     `pg:"addr"`
+		Backup  *net.IP    `pg:"backup"`
+		Status  testStatus `pg:"status"`
+	}
+	var account Account
+	err := Scan(newTestRows([]string{"balance", "addr", "backup", "status"},
+		[]interface{}{"$12.34", []byte("10.0.0.1"), "::1", []byte("disabled")},
+	), &account)
+	Assert(t, err, NilVal())
+	Assert(t, account.Balance, Equal(testMoney(1234)))
+	Assert(t, account.Addr.String(), Equal("10.0.0.1"))
+	Assert(t, account.Backup.String(), Equal("::1"))
+	Assert(t, account.Status, Equal(testStatus(2)))
+}
+
+func TestTimeFormatTag(t *testing.T) {
+	type Event struct {
+		Day   time.Time   `pg:"day,format=02/01/2006"`
+		Days  []time.Time `pg:"days,format=20060102"`
+		Label string      `pg:"at,format=2006-01-02"`
+	}
+	at := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	var event Event
+	err := Scan(newTestRows([]string{"day", "days", "at"},
+		[]interface{}{"25/12/2019", "{20200101,20200102}", at},
+	), &event)
+	Assert(t, err, NilVal())
+	Assert(t, event.Day, Equal(time.Date(2019, 12, 25, 0, 0, 0, 0, time.UTC)))
+	Assert(t, event.Days[1], Equal(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)))
+	Assert(t, event.Label, Equal("2020-03-04"))
+
+	var defaultEvent struct {
+		At string `pg:"at"`
+	}
+	Assert(t, Scan(newTestRows([]string{"at"}, []interface{}{at}), &defaultEvent), NilVal())
+	Assert(t, defaultEvent.At, Equal("2020-03-04 05:06:07"))
+
+	var bad Event
+	err = Scan(newTestRows([]string{"day"}, []interface{}{"2019-12-25"}), &bad)
+	Assert(t, errors.Is(err, ErrConvertValue), Equal(true))
+
+	//格式中含有逗号
+	_, options := parseTag("sent,json,format=Mon, 02 Jan 2006 15:04:05 MST")
+	Assert(t, options, Equal(map[string]string{TagOptionJSON: "", TagOptionFormat: time.RFC1123}))
+	var rfc struct {
+		Sent time.Time `pg:"sent,format=Mon, 02 Jan 2006 15:04:05 MST"`
+		Text string    `pg:"text,format=Mon, 02 Jan 2006"`
+	}
+	Assert(t, Scan(newTestRows([]string{"sent", "text"}, []interface{}{"Wed, 04 Mar 2020 05:06:07 UTC", at}), &rfc), NilVal())
+	Assert(t, rfc.Sent.Equal(at), Equal(true))
+	Assert(t, rfc.Text, Equal("Wed, 04 Mar 2020"))
+}
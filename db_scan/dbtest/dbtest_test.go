@@ -0,0 +1,89 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/tevid/go-tevid-utils/db_scan"
+	. "github.com/tevid/gohamcrest"
+	"testing"
+)
+
+type user struct {
+	ID      int64   `pg:"id"`
+	Name    string  `pg:"name"`
+	Balance *string `pg:"balance"`
+}
+
+func TestRows(t *testing.T) {
+	rows := NewRows([]string{"id", "name"}).AddRow(int64(1), []byte("tom")).AddRow(int64(2), nil)
+	var id int64
+	var name string
+	var ptr *string
+	Assert(t, rows.Next(), Equal(true))
+	Assert(t, rows.Scan(&id, &name), NilVal())
+	Assert(t, id, Equal(int64(1)))
+	Assert(t, name, Equal("tom"))
+	Assert(t, rows.Next(), Equal(true))
+	Assert(t, rows.Scan(&id, &ptr), NilVal())
+	Assert(t, ptr, NilVal())
+	Assert(t, errors.Is(rows.Scan(&id, &name), ErrUnsupported), Equal(true))
+	Assert(t, rows.Scan(&id), Equal(ErrDestCount))
+	Assert(t, rows.Next(), Equal(false))
+	Assert(t, rows.Err(), NilVal())
+
+	errIterate := errors.New("broken")
+	rows = NewRows([]string{"id"}).SetErr(errIterate)
+	Assert(t, rows.Err(), NilVal())
+	Assert(t, rows.Next(), Equal(false))
+	Assert(t, rows.Err(), Equal(errIterate))
+	rows.Close()
+	Assert(t, rows.Closed(), Equal(true))
+}
+
+func TestDriver(t *testing.T) {
+	d := NewDriver()
+	Assert(t, d.LoadFile("SELECT * FROM users WHERE id > $1", "testdata/users.csv"), NilVal())
+	Assert(t, d.LoadFile("SELECT * FROM profiles", "testdata/users.json"), NilVal())
+	db := d.DB()
+	defer db.Close()
+	ctx := context.Background()
+
+	var users []user
+	err := db_scan.Select(ctx, db, &users, "SELECT *\n\tFROM users WHERE id > $1", 0)
+	Assert(t, err, NilVal())
+	Assert(t, len(users), Equal(2))
+	Assert(t, *users[0].Balance, Equal("12.50"))
+	Assert(t, users[1].Name, Equal("jerry, jr"))
+	Assert(t, users[1].Balance, NilVal())
+	Assert(t, d.OpenRows(), Equal(0))
+
+	type profile struct {
+		ID      int64          `pg:"id"`
+		Name    *string        `pg:"name"`
+		Profile map[string]int `pg:"profile,json"`
+	}
+	var profiles []profile
+	Assert(t, db_scan.Select(ctx, db, &profiles, "SELECT * FROM profiles"), NilVal())
+	Assert(t, profiles[0].Profile, Equal(map[string]int{"age": 3}))
+	Assert(t, profiles[1].Name, NilVal())
+
+	tx, err := db.BeginTx(ctx, nil)
+	Assert(t, err, NilVal())
+	_, err = tx.ExecContext(ctx, "UPDATE users SET name = $1", "x")
+	Assert(t, err, NilVal())
+	Assert(t, tx.Commit(), NilVal())
+
+	_, err = db.QueryContext(ctx, "SELECT 1")
+	Assert(t, errors.Is(err, ErrUnknownQuery), Equal(true))
+
+	queries := d.Queries()
+	Assert(t, len(queries), Equal(4))
+	Assert(t, queries[0].Args, Equal([]interface{}{int64(0)}))
+	Assert(t, queries[2], Equal(Query{SQL: "UPDATE users SET name = $1", Args: []interface{}{"x"}}))
+
+	d.Register("SELECT count(*) FROM users", &ResultSet{Columns: []string{"count"}, Rows: nil})
+	var count int
+	err = db_scan.Get(ctx, db, &count, "SELECT count(*) FROM users")
+	Assert(t, errors.Is(err, sql.ErrNoRows), Equal(true))
+}
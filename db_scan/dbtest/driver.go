@@ -0,0 +1,359 @@
+package dbtest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//CSV中表示NULL的值
+const CSVNull = "NULL"
+
+var (
+	ErrUnknownQuery = errors.New("没有为该查询注册结果集")
+	ErrEmptyFixture = errors.New("fixture缺少列名")
+)
+
+type (
+	//按查询语句返回预先注册的结果集的驱动，不需要数据库服务即可测试查询和扫描代码
+	//查询语句比较前会合并连续的空白，参数不参与匹配，所有执行过的语句可通过Queries获取
+	Driver struct {
+		mu       sync.Mutex
+		handlers map[string]Handler
+		queries  []Query
+		open     int32 //未关闭的结果集数量
+	}
+
+	//按参数生成查询结果的函数，返回多个结果集时可通过NextResultSet依次读取
+	Handler func(args []interface{}) ([]*ResultSet, error)
+
+	//一个结果集
+	ResultSet struct {
+		Columns []string
+		Types   []string         //各列的数据库类型名，可为空，通过ColumnTypes的DatabaseTypeName返回
+		Rows    [][]driver.Value //nil表示NULL
+		Err     error            //迭代完所有行后返回的错误
+	}
+
+	//执行过的语句
+	Query struct {
+		SQL  string
+		Args []interface{}
+	}
+
+	//JSON fixture的格式
+	jsonFixture struct {
+		Columns []string        `json:"columns"`
+		Types   []string        `json:"types"`
+		Rows    [][]interface{} `json:"rows"`
+	}
+
+	conn struct {
+		driver *Driver
+	}
+
+	stmt struct {
+		conn  *conn
+		query string
+	}
+
+	tx struct{}
+
+	rows struct {
+		driver *Driver
+		sets   []*ResultSet
+		set    int //当前结果集
+		cursor int
+		closed bool
+	}
+
+	connector struct {
+		driver *Driver
+	}
+)
+
+func NewDriver() *Driver {
+	return &Driver{handlers: make(map[string]Handler)}
+}
+
+//打开使用该驱动的*sql.DB，不需要调用sql.Register
+func (d *Driver) DB() *sql.DB {
+	return sql.OpenDB(&connector{driver: d})
+}
+
+//注册查询语句对应的结果集，重复注册时覆盖
+func (d *Driver) Register(query string, result *ResultSet) {
+	d.RegisterMulti(query, result)
+}
+
+//注册查询语句对应的多个结果集，模拟存储过程或批量查询
+func (d *Driver) RegisterMulti(query string, results ...*ResultSet) {
+	d.Handle(query, func([]interface{}) ([]*ResultSet, error) {
+		return results, nil
+	})
+}
+
+//注册查询语句的处理函数，结果依赖参数时使用，如分页查询
+func (d *Driver) Handle(query string, handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[normalizeQuery(query)] = handler
+}
+
+//从CSV加载结果集：第一行为列名，列名可带类型如id:INT8，值为NULL的单元格表示NULL
+//其他值以[]byte返回，与数据库文本协议一致
+func (d *Driver) LoadCSV(query string, r io.Reader) error {
+	records, err := csv.NewReader(r).ReadAll()
+	if nil != err {
+		return err
+	}
+	if len(records) == 0 {
+		return ErrEmptyFixture
+	}
+	result := &ResultSet{}
+	for _, header := range records[0] {
+		name, typeName := header, ""
+		if idx := strings.Index(header, ":"); idx >= 0 {
+			name, typeName = header[:idx], header[idx+1:]
+		}
+		result.Columns = append(result.Columns, name)
+		result.Types = append(result.Types, typeName)
+	}
+	for _, record := range records[1:] {
+		row := make([]driver.Value, len(record))
+		for i, cell := range record {
+			if cell != CSVNull {
+				row[i] = []byte(cell)
+			}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	d.Register(query, result)
+	return nil
+}
+
+//从JSON加载结果集，格式为{"columns":[...],"types":[...],"rows":[[...],...]}，types可省略
+//整数转为int64，其他数字转为float64，null表示NULL
+func (d *Driver) LoadJSON(query string, r io.Reader) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	var fixture jsonFixture
+	if err := decoder.Decode(&fixture); nil != err {
+		return err
+	}
+	if len(fixture.Columns) == 0 {
+		return ErrEmptyFixture
+	}
+	result := &ResultSet{Columns: fixture.Columns, Types: fixture.Types}
+	for _, values := range fixture.Rows {
+		row := make([]driver.Value, len(values))
+		for i, value := range values {
+			row[i] = jsonValue(value)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	d.Register(query, result)
+	return nil
+}
+
+//从文件加载结果集，按扩展名选择CSV或JSON
+func (d *Driver) LoadFile(query string, path string) error {
+	data, err := os.ReadFile(path)
+	if nil != err {
+		return err
+	}
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		return d.LoadJSON(query, bytes.NewReader(data))
+	}
+	return d.LoadCSV(query, bytes.NewReader(data))
+}
+
+//未关闭的结果集数量，用于检查被测代码是否关闭了结果集
+func (d *Driver) OpenRows() int {
+	return int(atomic.LoadInt32(&d.open))
+}
+
+//执行过的查询和命令，按执行顺序排列
+func (d *Driver) Queries() []Query {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Query(nil), d.queries...)
+}
+
+func (d *Driver) Open(string) (driver.Conn, error) {
+	return &conn{driver: d}, nil
+}
+
+//记录执行的语句，返回参数值
+func (d *Driver) record(query string, args []driver.NamedValue) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queries = append(d.queries, Query{SQL: query, Args: values})
+	return values
+}
+
+func (d *Driver) lookup(query string) (Handler, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	handler, ok := d.handlers[normalizeQuery(query)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownQuery, query)
+	}
+	return handler, nil
+}
+
+//合并连续的空白
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+//json.Number转为int64或float64
+func jsonValue(value interface{}) driver.Value {
+	switch v := value.(type) {
+	case json.Number:
+		if intVal, err := v.Int64(); nil == err {
+			return intVal
+		}
+		floatVal, _ := v.Float64()
+		return floatVal
+	case string, bool, nil:
+		return v
+	}
+	//对象和数组按JSON文本返回，与json/jsonb列一致
+	data, _ := json.Marshal(value)
+	return data
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open("")
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values := c.driver.record(query, args)
+	handler, err := c.driver.lookup(query)
+	if nil != err {
+		return nil, err
+	}
+	sets, err := handler(values)
+	if nil != err {
+		return nil, err
+	}
+	if len(sets) == 0 {
+		sets = []*ResultSet{{}}
+	}
+	atomic.AddInt32(&c.driver.open, 1)
+	return &rows{driver: c.driver, sets: sets}, nil
+}
+
+//命令不需要注册，总是成功且影响0行
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.record(query, args)
+	return driver.RowsAffected(0), nil
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+func (tx) Commit() error {
+	return nil
+}
+
+func (tx) Rollback() error {
+	return nil
+}
+
+func (r *rows) Columns() []string {
+	return r.sets[r.set].Columns
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	if types := r.sets[r.set].Types; index < len(types) {
+		return types[index]
+	}
+	return ""
+}
+
+func (r *rows) HasNextResultSet() bool {
+	return r.set+1 < len(r.sets)
+}
+
+func (r *rows) NextResultSet() error {
+	if !r.HasNextResultSet() {
+		return io.EOF
+	}
+	r.set++
+	r.cursor = 0
+	return nil
+}
+
+func (r *rows) Close() error {
+	if !r.closed {
+		r.closed = true
+		atomic.AddInt32(&r.driver.open, -1)
+	}
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	result := r.sets[r.set]
+	if r.cursor >= len(result.Rows) {
+		if nil != result.Err {
+			return result.Err
+		}
+		return io.EOF
+	}
+	copy(dest, result.Rows[r.cursor])
+	r.cursor++
+	return nil
+}
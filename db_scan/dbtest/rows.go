@@ -0,0 +1,156 @@
+package dbtest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	ErrRowsClosed  = errors.New("结果集已关闭")
+	ErrNoCurrent   = errors.New("没有当前行，需先调用Next")
+	ErrDestCount   = errors.New("Scan的参数个数与列数不一致")
+	ErrUnsupported = errors.New("不支持的Scan目标类型")
+)
+
+type (
+	//内存中的结果集，实现db_scan.IRows，Scan的行为与*sql.Rows接近：
+	//dest支持sql.Scanner、*interface{}以及可由列值直接赋值的指针
+	Rows struct {
+		sets   []rowSet
+		set    int //当前结果集
+		cursor int
+		closed bool
+		err    error
+	}
+
+	rowSet struct {
+		columns []string
+		rows    [][]interface{}
+	}
+)
+
+//由列名和各行的值构建结果集，行中的nil表示NULL
+func NewRows(columns []string, rows ...[]interface{}) *Rows {
+	return &Rows{sets: []rowSet{{columns: columns, rows: rows}}, cursor: -1}
+}
+
+//追加一个结果集，通过NextResultSet切换，模拟存储过程或批量查询
+func (r *Rows) AddResultSet(columns []string, rows ...[]interface{}) *Rows {
+	r.sets = append(r.sets, rowSet{columns: columns, rows: rows})
+	return r
+}
+
+//切换到下一个结果集，没有更多结果集时返回false
+func (r *Rows) NextResultSet() bool {
+	if r.closed || r.set+1 >= len(r.sets) {
+		return false
+	}
+	r.set++
+	r.cursor = -1
+	return true
+}
+
+//设置迭代完所有行后Err返回的错误，模拟连接中断等迭代中的错误
+func (r *Rows) SetErr(err error) *Rows {
+	r.err = err
+	return r
+}
+
+//在最后一个结果集中追加一行
+func (r *Rows) AddRow(values ...interface{}) *Rows {
+	last := &r.sets[len(r.sets)-1]
+	last.rows = append(last.rows, values)
+	return r
+}
+
+func (r *Rows) Close() error {
+	r.closed = true
+	return nil
+}
+
+//是否已调用Close，用于检查被测代码是否关闭了结果集
+func (r *Rows) Closed() bool {
+	return r.closed
+}
+
+func (r *Rows) Columns() ([]string, error) {
+	if r.closed {
+		return nil, ErrRowsClosed
+	}
+	return r.sets[r.set].columns, nil
+}
+
+func (r *Rows) Next() bool {
+	rows := r.sets[r.set].rows
+	if r.closed || r.cursor >= len(rows) {
+		return false
+	}
+	r.cursor++
+	return r.cursor < len(rows)
+}
+
+//迭代完最后一个结果集后返回SetErr设置的错误
+func (r *Rows) Err() error {
+	if r.set == len(r.sets)-1 && r.cursor >= len(r.sets[r.set].rows) {
+		return r.err
+	}
+	return nil
+}
+
+func (r *Rows) Scan(dest ...interface{}) error {
+	if r.closed {
+		return ErrRowsClosed
+	}
+	current := r.sets[r.set]
+	if r.cursor < 0 || r.cursor >= len(current.rows) {
+		return ErrNoCurrent
+	}
+	row := current.rows[r.cursor]
+	if len(dest) != len(current.columns) || len(row) != len(current.columns) {
+		return ErrDestCount
+	}
+	for i, d := range dest {
+		if err := assign(d, row[i]); nil != err {
+			return fmt.Errorf("列%s: %w", current.columns[i], err)
+		}
+	}
+	return nil
+}
+
+//把列值赋给Scan的目标
+func assign(dest, value interface{}) error {
+	switch d := dest.(type) {
+	case sql.Scanner:
+		return d.Scan(value)
+	case *interface{}:
+		*d = value
+		return nil
+	}
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.IsNil() {
+		return ErrUnsupported
+	}
+	target := destValue.Elem()
+	if nil == value {
+		switch target.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			target.Set(reflect.Zero(target.Type()))
+			return nil
+		}
+		return ErrUnsupported
+	}
+	source := reflect.ValueOf(value)
+	switch {
+	case source.Type().AssignableTo(target.Type()):
+		target.Set(source)
+	case source.Type().ConvertibleTo(target.Type()) && source.Kind() == target.Kind():
+		target.Set(source.Convert(target.Type()))
+	case target.Kind() == reflect.String && source.Kind() == reflect.Slice && source.Type().Elem().Kind() == reflect.Uint8:
+		target.SetString(string(source.Bytes()))
+	default:
+		return ErrUnsupported
+	}
+	return nil
+}
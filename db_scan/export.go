@@ -0,0 +1,173 @@
+package db_scan
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//文本表格中NULL的默认表示，CSV中NULL默认为空字段
+const TableNull = "NULL"
+
+type (
+	//导出选项
+	ExportOption func(*exportOptions)
+
+	exportOptions struct {
+		nullText string
+	}
+)
+
+//NULL的表示，如需与空字符串区分时CSV可使用\N或NULL
+func WithNullText(text string) ExportOption {
+	return func(o *exportOptions) {
+		o.nullText = text
+	}
+}
+
+func newExportOptions(nullText string, opts []ExportOption) exportOptions {
+	options := exportOptions{nullText: nullText}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+//把结果集导出为CSV，第一行为列名，逐行写出不会缓存整个结果集
+//NULL默认写为空字段，可通过WithNullText修改
+func ExportCSV(w io.Writer, rows IRows, opts ...ExportOption) error {
+	options := newExportOptions("", opts)
+	columns, err := rows.Columns()
+	if nil != err {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	if err = writer.Write(columns); nil != err {
+		return err
+	}
+	record := make([]string, len(columns))
+	err = EachRow(rows, func(row Row) error {
+		for i, value := range row.Values {
+			text, null := exportText(value)
+			if null {
+				text = options.nullText
+			}
+			record[i] = text
+		}
+		return writer.Write(record)
+	})
+	writer.Flush()
+	if nil == err {
+		err = writer.Error()
+	}
+	return err
+}
+
+//把结果集导出为JSON lines，每行一个按列顺序输出的JSON对象
+func ExportJSONLines(w io.Writer, rows IRows) error {
+	writer := bufio.NewWriter(w)
+	err := EachRow(rows, func(row Row) error {
+		data, err := json.Marshal(row)
+		if nil != err {
+			return err
+		}
+		writer.Write(data)
+		return writer.WriteByte('\n')
+	})
+	if flushErr := writer.Flush(); nil == err {
+		err = flushErr
+	}
+	return err
+}
+
+//把结果集导出为对齐的文本表格，需要缓存整个结果集以计算列宽，中日韩字符按两个宽度计算，如：
+//+----+------+
+//| id | name |
+//+----+------+
+//| 1  | tom  |
+//+----+------+
+//NULL默认写为TableNull，可通过WithNullText修改
+func ExportTable(w io.Writer, rows IRows, opts ...ExportOption) error {
+	options := newExportOptions(TableNull, opts)
+	columns, err := rows.Columns()
+	if nil != err {
+		return err
+	}
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = displayWidth(column)
+	}
+	var records [][]string
+	err = EachRow(rows, func(row Row) error {
+		record := make([]string, len(row.Values))
+		for i, value := range row.Values {
+			text, null := exportText(value)
+			if null {
+				text = options.nullText
+			}
+			//换行和制表符会破坏对齐
+			text = strings.NewReplacer("\r", `\r`, "\n", `\n`, "\t", `\t`).Replace(text)
+			record[i] = text
+			if width := displayWidth(text); width > widths[i] {
+				widths[i] = width
+			}
+		}
+		records = append(records, record)
+		return nil
+	})
+	if nil != err {
+		return err
+	}
+
+	writer := bufio.NewWriter(w)
+	writeTableBorder(writer, widths)
+	writeTableLine(writer, widths, columns)
+	writeTableBorder(writer, widths)
+	for _, record := range records {
+		writeTableLine(writer, widths, record)
+	}
+	if len(records) > 0 {
+		writeTableBorder(writer, widths)
+	}
+	return writer.Flush()
+}
+
+func writeTableBorder(writer *bufio.Writer, widths []int) {
+	writer.WriteByte('+')
+	for _, width := range widths {
+		writer.WriteString(strings.Repeat("-", width+2))
+		writer.WriteByte('+')
+	}
+	writer.WriteByte('\n')
+}
+
+func writeTableLine(writer *bufio.Writer, widths []int, cells []string) {
+	writer.WriteByte('|')
+	for i, cell := range cells {
+		writer.WriteByte(' ')
+		writer.WriteString(cell)
+		writer.WriteString(strings.Repeat(" ", widths[i]-displayWidth(cell)+1))
+		writer.WriteByte('|')
+	}
+	writer.WriteByte('\n')
+}
+
+//终端中的显示宽度，中日韩字符和全角字符按2计算
+func displayWidth(str string) int {
+	width := 0
+	for len(str) > 0 {
+		r, size := utf8.DecodeRuneInString(str)
+		str = str[size:]
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hangul, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || r >= 0xFF01 && r <= 0xFF60 || r >= 0x3000 && r <= 0x303F {
+			width += 2
+		} else {
+			width++
+		}
+	}
+	return width
+}
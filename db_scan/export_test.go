@@ -0,0 +1,85 @@
+package db_scan
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"github.com/tevid/go-tevid-utils/db_scan/dbtest"
+	. "github.com/tevid/gohamcrest"
+	"testing"
+	"time"
+)
+
+func newExportRows() *dbtest.Rows {
+	at := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	return dbtest.NewRows([]string{"name", "id", "score", "at"},
+		[]interface{}{[]byte("张三"), int64(2), 1.5, at},
+		[]interface{}{"b,\"c\"", int64(10), nil, nil},
+	)
+}
+
+func TestRow(t *testing.T) {
+	var rows []Row
+	Assert(t, Scan(newExportRows(), &rows), NilVal())
+	Assert(t, rows[0].Columns, Equal([]string{"name", "id", "score", "at"}))
+	Assert(t, rows[0].Values[0], Equal([]byte("张三")))
+	value, ok := rows[1].Get("id")
+	Assert(t, ok, Equal(true))
+	Assert(t, value, Equal(int64(10)))
+	Assert(t, rows[1].Map()["score"], NilVal())
+
+	data, err := json.Marshal(rows[0])
+	Assert(t, err, NilVal())
+	Assert(t, string(data), Equal(`{"name":"张三","id":2,"score":1.5,"at":"2020-01-02 03:04:05"}`))
+
+	var row Row
+	Assert(t, Scan(dbtest.NewRows([]string{"id"}), &row), Equal(ErrEmptyResult))
+
+	//数值列按列类型转换，与扫描到结构体时一致
+	d := dbtest.NewDriver()
+	d.Register("q", &dbtest.ResultSet{
+		Columns: []string{"id", "amount"},
+		Types:   []string{"INT4", "NUMERIC"},
+		Rows:    [][]driver.Value{{[]byte("7"), []byte("1.50")}},
+	})
+	db := d.DB()
+	defer db.Close()
+	sqlRows, err := db.Query("q")
+	Assert(t, err, NilVal())
+	defer sqlRows.Close()
+	Assert(t, Scan(sqlRows, &row), NilVal())
+	Assert(t, row.Values, Equal([]interface{}{int64(7), "1.50"}))
+}
+
+func TestExport(t *testing.T) {
+	var buf bytes.Buffer
+	Assert(t, ExportCSV(&buf, newExportRows()), NilVal())
+	Assert(t, buf.String(), Equal("name,id,score,at\n张三,2,1.5,2020-01-02 03:04:05\n\"b,\"\"c\"\"\",10,,\n"))
+
+	//NULL与字符串'NULL'区分
+	buf.Reset()
+	nullRows := dbtest.NewRows([]string{"a", "b"}, []interface{}{"NULL", nil})
+	Assert(t, ExportCSV(&buf, nullRows, WithNullText(`\N`)), NilVal())
+	Assert(t, buf.String(), Equal("a,b\nNULL,\\N\n"))
+
+	buf.Reset()
+	Assert(t, ExportJSONLines(&buf, newExportRows()), NilVal())
+	Assert(t, buf.String(), Equal(`{"name":"张三","id":2,"score":1.5,"at":"2020-01-02 03:04:05"}`+"\n"+
+		`{"name":"b,\"c\"","id":10,"score":null,"at":null}`+"\n"))
+
+	buf.Reset()
+	Assert(t, ExportTable(&buf, newExportRows()), NilVal())
+	Assert(t, buf.String(), Equal(""+
+		"+-------+----+-------+---------------------+\n"+
+		"| name  | id | score | at                  |\n"+
+		"+-------+----+-------+---------------------+\n"+
+		"| 张三  | 2  | 1.5   | 2020-01-02 03:04:05 |\n"+
+		"| b,\"c\" | 10 | NULL  | NULL                |\n"+
+		"+-------+----+-------+---------------------+\n"))
+
+	//不是UTF-8的二进制值按base64导出
+	buf.Reset()
+	binary := []byte{0xff, 0x00, 0xfe}
+	Assert(t, ExportJSONLines(&buf, dbtest.NewRows([]string{"data"}, []interface{}{binary})), NilVal())
+	Assert(t, buf.String(), Equal(`{"data":"/wD+"}`+"\n"))
+}
@@ -0,0 +1,207 @@
+package db_scan
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+const (
+	TagOptionPrefix = "prefix" //嵌套结构体或一对多的子结构体切片，标签名作为列名前缀
+	TagOptionPK     = "pk"     //主键，一对多时按主键合并多行
+)
+
+var ErrNoPrimaryKey = errors.New("包含一对多关联的结构体缺少pk标签字段")
+
+type (
+	//结构体到列的映射
+	structMapping struct {
+		scanner   *Scanner
+		fields    map[string]*fieldMapping //列名到字段，不区分大小写时为小写列名
+		ordered   []*fieldMapping          //按结构体定义顺序排列的字段
+		pk        *fieldMapping            //主结构体的主键
+		relations []*relationMapping       //一对多关联
+	}
+
+	//单个字段的映射
+	fieldMapping struct {
+		name    string
+		path    string            //字段路径，如Author.ID，用于错误信息
+		index   []int             //字段在所属根结构体中的索引路径
+		root    int               //0表示主结构体，i表示第i个一对多关联的子结构体
+		options map[string]string //标签选项
+	}
+
+	//一对多关联：主结构体中的子结构体切片
+	relationMapping struct {
+		index      []int        //切片字段的索引路径
+		elemType   reflect.Type //切片元素类型，结构体或结构体指针
+		structType reflect.Type
+		pk         *fieldMapping //子结构体的主键，用于去重
+	}
+)
+
+//解析标签，如`pg:"author_,prefix"`，返回列名和选项
+//时间格式中可能含有逗号，如RFC1123，format选项需放在最后，取其后的全部内容
+func parseTag(tag string) (string, map[string]string) {
+	parts := strings.Split(tag, ",")
+	options := make(map[string]string, len(parts)-1)
+	for i, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if strings.HasPrefix(part, TagOptionFormat+"=") {
+			options[TagOptionFormat] = strings.Join(append([]string{part[len(TagOptionFormat)+1:]}, parts[i+2:]...), ",")
+			break
+		}
+		if idx := strings.Index(part, "="); idx >= 0 {
+			options[part[:idx]] = part[idx+1:]
+		} else {
+			options[part] = ""
+		}
+	}
+	return strings.TrimSpace(parts[0]), options
+}
+
+//收集结构体的字段，index为结构体在根结构体中的索引路径，prefix为列名前缀，path为字段路径前缀
+//同名列以层级较浅的字段为准，与Go的字段提升规则一致
+func (m *structMapping) addFields(structType reflect.Type, index []int, prefix, path string, root int) {
+	var embedded []reflect.StructField
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		_, hasTag := field.Tag.Lookup(m.scanner.tagName)
+		//没有标签的匿名字段，提升其中的字段
+		if field.Anonymous && !hasTag {
+			if isStructType(field.Type) && (field.PkgPath == "" || field.Type.Kind() != reflect.Ptr) {
+				embedded = append(embedded, field)
+			}
+			continue
+		}
+		name, options := m.scanner.columnName(field)
+		if field.PkgPath != "" || name == "" {
+			continue
+		}
+
+		fieldIndex := appendIndex(index, i)
+		if _, ok := options[TagOptionPrefix]; ok {
+			if isStructType(field.Type) {
+				m.addFields(derefType(field.Type), fieldIndex, prefix+name, path+field.Name+".", root)
+				continue
+			}
+			//一对多只支持主结构体中的切片
+			if root == 0 && field.Type.Kind() == reflect.Slice && isStructType(field.Type.Elem()) {
+				m.addRelation(field, fieldIndex, prefix+name, path+field.Name+".")
+				continue
+			}
+		}
+		m.add(&fieldMapping{name: prefix + name, path: path + field.Name, index: fieldIndex, root: root, options: options})
+	}
+	for _, field := range embedded {
+		m.addFields(derefType(field.Type), appendIndex(index, field.Index[0]), prefix, path, root)
+	}
+}
+
+func (m *structMapping) addRelation(field reflect.StructField, index []int, prefix, path string) {
+	relation := &relationMapping{
+		index:      index,
+		elemType:   field.Type.Elem(),
+		structType: derefType(field.Type.Elem()),
+	}
+	m.relations = append(m.relations, relation)
+	m.addFields(relation.structType, nil, prefix, path, len(m.relations))
+}
+
+func (m *structMapping) add(field *fieldMapping) {
+	key := m.scanner.columnKey(field.name)
+	if _, ok := m.fields[key]; ok {
+		return
+	}
+	m.fields[key] = field
+	m.ordered = append(m.ordered, field)
+	if _, ok := field.options[TagOptionPK]; !ok {
+		return
+	}
+	if field.root == 0 {
+		if nil == m.pk {
+			m.pk = field
+		}
+	} else if relation := m.relations[field.root-1]; nil == relation.pk {
+		relation.pk = field
+	}
+}
+
+//把src中一对多关联的子结构体合并到dst，子结构体有主键时去重
+func (m *structMapping) merge(dst, src reflect.Value) {
+	for _, relation := range m.relations {
+		dstSlice := fieldByIndex(dst, relation.index, true)
+		srcSlice := fieldByIndex(src, relation.index, true)
+		for i := 0; i < srcSlice.Len(); i++ {
+			child := srcSlice.Index(i)
+			if nil != relation.pk && containsChild(dstSlice, child, relation.pk) {
+				continue
+			}
+			dstSlice.Set(reflect.Append(dstSlice, child))
+		}
+	}
+}
+
+func containsChild(children, child reflect.Value, pk *fieldMapping) bool {
+	key := pkKey(reflect.Indirect(child), pk)
+	for i := 0; i < children.Len(); i++ {
+		if pkKey(reflect.Indirect(children.Index(i)), pk) == key {
+			return true
+		}
+	}
+	return false
+}
+
+//主键的值，用作map的key
+func pkKey(structValue reflect.Value, pk *fieldMapping) interface{} {
+	value := fieldByIndex(structValue, pk.index, false)
+	if !value.IsValid() {
+		return nil
+	}
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if isBytes(value.Type()) {
+		return string(value.Bytes())
+	}
+	return value.Interface()
+}
+
+//按索引路径获取字段，途经的结构体指针为nil时：alloc为true则分配，否则返回无效值
+func fieldByIndex(value reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+	return value
+}
+
+func appendIndex(index []int, i int) []int {
+	return append(index[:len(index):len(index)], i)
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func isStructType(t reflect.Type) bool {
+	return derefType(t).Kind() == reflect.Struct
+}
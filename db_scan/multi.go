@@ -0,0 +1,56 @@
+package db_scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrNoResultSet = errors.New("结果集数量少于目标数量")
+
+//包含多个结果集的结果，*sql.Rows实现了该接口
+type multiResultRows interface {
+	NextResultSet() bool
+}
+
+//使用默认扫描器把多个结果集依次扫描到targets中
+func ScanMulti(rows IRows, targets ...interface{}) error {
+	return defaultScanner.ScanMulti(rows, targets...)
+}
+
+//使用默认扫描器执行返回多个结果集的查询，如存储过程、批量查询
+func SelectMulti(ctx context.Context, q Querier, targets []interface{}, query string, args ...interface{}) error {
+	return defaultScanner.SelectMulti(ctx, q, targets, query, args...)
+}
+
+//第i个结果集扫描到targets[i]中，每个target的规则与Scan相同，结果集少于targets时返回ErrNoResultSet
+func (s *Scanner) ScanMulti(rows IRows, targets ...interface{}) error {
+	for i, target := range targets {
+		if i > 0 {
+			r, ok := rows.(multiResultRows)
+			if !ok || !r.NextResultSet() {
+				if err := rowsErr(rows); nil != err {
+					return err
+				}
+				return fmt.Errorf("%w: 第%d个目标没有对应的结果集", ErrNoResultSet, i+1)
+			}
+		}
+		if err := s.Scan(rows, target); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+//执行返回多个结果集的查询并依次扫描到targets中，结果集总是会被关闭
+func (s *Scanner) SelectMulti(ctx context.Context, q Querier, targets []interface{}, query string, args ...interface{}) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if nil != err {
+		return err
+	}
+	err = s.ScanMulti(rows, targets...)
+	if closeErr := rows.Close(); nil == err {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,285 @@
+package db_scan
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+//占位符风格
+type Dialect int
+
+const (
+	DialectPostgres Dialect = iota //$1、$2...
+	DialectMySQL                   //?
+)
+
+var (
+	ErrMissingParam = errors.New("命名参数没有对应的值")
+	ErrEmptyIn      = errors.New("展开的切片参数为空")
+	ErrParamSource  = errors.New("命名参数的来源需为结构体或key为string的map")
+	ErrEmptyInsert  = errors.New("批量插入的数据为空")
+)
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+//占位符使用的风格，默认为DialectPostgres
+func WithDialect(dialect Dialect) ScannerOption {
+	return func(s *Scanner) {
+		s.dialect = dialect
+	}
+}
+
+//第n个参数的占位符，n从1开始
+func (d Dialect) placeholder(n int) string {
+	if d == DialectMySQL {
+		return "?"
+	}
+	return "$" + strconv.Itoa(n)
+}
+
+//使用默认扫描器展开命名参数
+func Named(query string, arg interface{}) (string, []interface{}, error) {
+	return defaultScanner.Named(query, arg)
+}
+
+//使用默认扫描器生成批量插入语句
+func BulkInsert(table string, rows interface{}) (string, []interface{}, error) {
+	return defaultScanner.BulkInsert(table, rows)
+}
+
+//把query中的:name命名参数展开为位置占位符，参数值按标签从结构体字段中获取，或从map中按key获取
+//值为切片（[]byte和driver.Valuer除外）时展开为多个占位符，用于IN (:ids)
+//引号和注释（--、/* */）中的内容、::类型转换以及arr[1:2]这类切片下标不做处理
+//参数名需以字母或_开头，且:前不能是标识符字符或[
+func (s *Scanner) Named(query string, arg interface{}) (string, []interface{}, error) {
+	lookup, err := s.paramLookup(arg)
+	if nil != err {
+		return "", nil, err
+	}
+	var builder strings.Builder
+	builder.Grow(len(query))
+	var args []interface{}
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := skipQuoted(query, i)
+			builder.WriteString(query[i:end])
+			i = end
+		case c == '-' && strings.HasPrefix(query[i:], "--"), c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := skipComment(query, i)
+			builder.WriteString(query[i:end])
+			i = end
+		case c == ':' && i+1 < len(query) && query[i+1] == ':':
+			builder.WriteString("::")
+			i += 2
+		case c == ':' && i+1 < len(query) && isNameStart(query[i+1]) && (i == 0 || !isNameChar(query[i-1]) && query[i-1] != '['):
+			end := i + 1
+			for end < len(query) && isNameChar(query[end]) {
+				end++
+			}
+			name := query[i+1 : end]
+			value, err := lookup(name)
+			if nil != err {
+				return "", nil, fmt.Errorf("%w: %s", err, name)
+			}
+			if args, err = s.bindValue(&builder, args, value); nil != err {
+				return "", nil, fmt.Errorf("%w: %s", err, name)
+			}
+			i = end
+		default:
+			builder.WriteByte(c)
+			i++
+		}
+	}
+	return builder.String(), args, nil
+}
+
+//生成批量插入语句：INSERT INTO table (列...) VALUES (...), (...)
+//rows为结构体或结构体指针的切片，列为所有映射到主结构体的字段，按定义顺序排列
+func (s *Scanner) BulkInsert(table string, rows interface{}) (string, []interface{}, error) {
+	valueObj := reflect.Indirect(reflect.ValueOf(rows))
+	if valueObj.Kind() != reflect.Slice || derefType(valueObj.Type().Elem()).Kind() != reflect.Struct {
+		return "", nil, ErrParamSource
+	}
+	if valueObj.Len() == 0 {
+		return "", nil, ErrEmptyInsert
+	}
+	fields := s.insertFields(derefType(valueObj.Type().Elem()))
+
+	var builder strings.Builder
+	builder.WriteString("INSERT INTO ")
+	builder.WriteString(table)
+	builder.WriteString(" (")
+	for i, field := range fields {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(field.name)
+	}
+	builder.WriteString(") VALUES ")
+	args := make([]interface{}, 0, len(fields)*valueObj.Len())
+	for i := 0; i < valueObj.Len(); i++ {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		builder.WriteByte('(')
+		structValue := indirectValue(valueObj.Index(i))
+		for j, field := range fields {
+			if j > 0 {
+				builder.WriteString(", ")
+			}
+			value, err := fieldValue(structValue, field)
+			if nil != err {
+				return "", nil, fmt.Errorf("%w: %s", err, field.path)
+			}
+			args = append(args, value)
+			builder.WriteString(s.dialect.placeholder(len(args)))
+		}
+		builder.WriteByte(')')
+	}
+	return builder.String(), args, nil
+}
+
+//结构体映射到的列名，与扫描时使用的映射相同，不包含一对多关联的子结构体
+func (s *Scanner) Columns(structType reflect.Type) []string {
+	fields := s.insertFields(derefType(structType))
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.name
+	}
+	return columns
+}
+
+func (s *Scanner) insertFields(structType reflect.Type) []*fieldMapping {
+	if structType.Kind() != reflect.Struct {
+		return nil
+	}
+	var fields []*fieldMapping
+	for _, field := range s.getStructMapping(structType).ordered {
+		if field.root == 0 {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+//按名称获取参数值的函数，没有对应的值时返回ErrMissingParam
+func (s *Scanner) paramLookup(arg interface{}) (func(string) (interface{}, error), error) {
+	valueObj := indirectValue(reflect.ValueOf(arg))
+	switch {
+	case valueObj.Kind() == reflect.Struct:
+		mapping := s.getStructMapping(valueObj.Type())
+		return func(name string) (interface{}, error) {
+			field, ok := mapping.fields[s.columnKey(name)]
+			if !ok || field.root != 0 {
+				return nil, ErrMissingParam
+			}
+			return fieldValue(valueObj, field)
+		}, nil
+	case valueObj.Kind() == reflect.Map && valueObj.Type().Key().Kind() == reflect.String:
+		return func(name string) (interface{}, error) {
+			value := valueObj.MapIndex(reflect.ValueOf(name).Convert(valueObj.Type().Key()))
+			if !value.IsValid() {
+				return nil, ErrMissingParam
+			}
+			return value.Interface(), nil
+		}, nil
+	}
+	return nil, ErrParamSource
+}
+
+//写入参数的占位符，切片展开为逗号分隔的多个占位符
+func (s *Scanner) bindValue(builder *strings.Builder, args []interface{}, value interface{}) ([]interface{}, error) {
+	valueObj := reflect.ValueOf(value)
+	if !isInSlice(valueObj) {
+		args = append(args, value)
+		builder.WriteString(s.dialect.placeholder(len(args)))
+		return args, nil
+	}
+	if valueObj.Len() == 0 {
+		return args, ErrEmptyIn
+	}
+	for i := 0; i < valueObj.Len(); i++ {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		args = append(args, valueObj.Index(i).Interface())
+		builder.WriteString(s.dialect.placeholder(len(args)))
+	}
+	return args, nil
+}
+
+//需要展开的切片参数
+func isInSlice(value reflect.Value) bool {
+	if !value.IsValid() || value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return false
+	}
+	return !isBytes(value.Type()) && !value.Type().Implements(valuerType)
+}
+
+//字段的值，途经的结构体指针为nil时返回nil
+//带json选项的字段编码为JSON文本，与扫描时的解码对应，nil的指针、map和切片为NULL
+func fieldValue(structValue reflect.Value, field *fieldMapping) (interface{}, error) {
+	value := fieldByIndex(structValue, field.index, false)
+	if !value.IsValid() {
+		return nil, nil
+	}
+	if _, ok := field.options[TagOptionJSON]; !ok {
+		return value.Interface(), nil
+	}
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if value.IsNil() {
+			return nil, nil
+		}
+	}
+	data, err := json.Marshal(value.Interface())
+	if nil != err {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+//跳过引号中的内容，返回结束引号之后的位置，两个连续的引号视为转义
+func skipQuoted(query string, begin int) int {
+	quote := query[begin]
+	for i := begin + 1; i < len(query); i++ {
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
+
+//跳过注释，返回注释之后的位置，--注释到行尾（含换行符），/* */注释到*/之后
+func skipComment(query string, begin int) int {
+	if query[begin] == '-' {
+		if idx := strings.IndexByte(query[begin:], '\n'); idx >= 0 {
+			return begin + idx + 1
+		}
+		return len(query)
+	}
+	if idx := strings.Index(query[begin+2:], "*/"); idx >= 0 {
+		return begin + 2 + idx + 2
+	}
+	return len(query)
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
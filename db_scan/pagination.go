@@ -0,0 +1,80 @@
+package db_scan
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+//游标列，按该列分页，如`pg:"id,cursor"`，可标记多列组成复合游标
+const TagOptionCursor = "cursor"
+
+//分页查询中每页行数的命名参数
+const LimitParam = "limit"
+
+var (
+	ErrNoCursor = errors.New("分页的结构体缺少cursor标签字段")
+	ErrPageSize = errors.New("每页行数必须大于0")
+)
+
+//使用默认扫描器按游标分页查询
+func Paginate[T any](ctx context.Context, q Querier, query string, args map[string]interface{}, pageSize int, fn func(page []T) error) error {
+	return PaginateWith(defaultScanner, ctx, q, query, args, pageSize, fn)
+}
+
+//按游标（keyset）分页查询，每页扫描为[]T交给fn，T需为带有cursor标签字段的结构体
+//query使用命名参数，游标以列名为参数名，每页行数为:limit，如：
+//SELECT * FROM users WHERE id > :id ORDER BY id LIMIT :limit
+//args为其他命名参数和首页的游标值，之后每页的游标值为上一页最后一行游标字段的值
+//某页不足pageSize行时结束，fn返回ErrStopScan时提前结束并返回nil；pageSize小于等于0时返回ErrPageSize
+func PaginateWith[T any](s *Scanner, ctx context.Context, q Querier, query string, args map[string]interface{}, pageSize int, fn func(page []T) error) error {
+	if pageSize <= 0 {
+		return ErrPageSize
+	}
+	structType := reflect.TypeOf((*T)(nil)).Elem()
+	if structType.Kind() != reflect.Struct {
+		return ErrTargetNotSettable
+	}
+	var cursors []*fieldMapping
+	for _, field := range s.getStructMapping(structType).ordered {
+		if _, ok := field.options[TagOptionCursor]; ok && field.root == 0 {
+			cursors = append(cursors, field)
+		}
+	}
+	if len(cursors) == 0 {
+		return ErrNoCursor
+	}
+
+	params := make(map[string]interface{}, len(args)+1)
+	for key, value := range args {
+		params[key] = value
+	}
+	params[LimitParam] = pageSize
+	for {
+		namedQuery, namedArgs, err := s.Named(query, params)
+		if nil != err {
+			return err
+		}
+		var page []T
+		if err = s.Select(ctx, q, &page, namedQuery, namedArgs...); nil != err {
+			return err
+		}
+		if len(page) > 0 {
+			if err = fn(page); nil != err {
+				if err == ErrStopScan {
+					return nil
+				}
+				return err
+			}
+		}
+		if len(page) < pageSize {
+			return nil
+		}
+		last := reflect.ValueOf(&page[len(page)-1]).Elem()
+		for _, field := range cursors {
+			if params[field.name], err = fieldValue(last, field); nil != err {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package db_scan
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+//JSON/JSONB列，按json解码到结构体、map或切片字段，如`pg:"profile,json"`
+const TagOptionJSON = "json"
+
+var (
+	ErrPGArray  = errors.New("PostgreSQL数组格式错误")
+	ErrPGHstore = errors.New("PostgreSQL hstore格式错误")
+)
+
+//解析PostgreSQL数组字面量并转换到切片，如{a,"b c",NULL}、{{1,2},{3,4}}
+//元素按字符串转换到切片元素类型，NULL元素交给handleConvertNull
+func convertPGArray(str string, format string, target reflect.Value) error {
+	//带维度下界的数组，如[0:1]={1,2}
+	if strings.HasPrefix(str, "[") {
+		idx := strings.Index(str, "=")
+		if idx < 0 {
+			return ErrPGArray
+		}
+		str = str[idx+1:]
+	}
+	elems, pos, err := parsePGArray(str, 0)
+	if nil != err {
+		return err
+	}
+	if pos != len(str) {
+		return ErrPGArray
+	}
+	return convertPGElems(elems, format, target)
+}
+
+func convertPGElems(elems []interface{}, format string, target reflect.Value) error {
+	slice := reflect.MakeSlice(target.Type(), len(elems), len(elems))
+	for i, elem := range elems {
+		elemValue := slice.Index(i)
+		var err error
+		switch elem := elem.(type) {
+		case nil:
+			err = handleConvertNull(elemValue)
+		case string:
+			err = valueConvertFormat(elem, elemValue, format)
+		case []interface{}:
+			if elemValue.Kind() != reflect.Slice || isBytes(elemValue.Type()) {
+				return ErrPGArray
+			}
+			err = convertPGElems(elem, format, elemValue)
+		}
+		if nil != err {
+			return err
+		}
+	}
+	target.Set(slice)
+	return nil
+}
+
+//从pos处的{开始解析一层数组，元素为nil（NULL）、string或[]interface{}（子数组），返回}之后的位置
+func parsePGArray(str string, pos int) ([]interface{}, int, error) {
+	if pos >= len(str) || str[pos] != '{' {
+		return nil, pos, ErrPGArray
+	}
+	pos++
+	elems := make([]interface{}, 0)
+	if pos < len(str) && str[pos] == '}' {
+		return elems, pos + 1, nil
+	}
+	for pos < len(str) {
+		var elem interface{}
+		var err error
+		switch str[pos] {
+		case '{':
+			elem, pos, err = parsePGArray(str, pos)
+		case '"':
+			elem, pos, err = parseQuoted(str, pos, ErrPGArray)
+		default:
+			begin := pos
+			for pos < len(str) && str[pos] != ',' && str[pos] != '}' {
+				pos++
+			}
+			value := strings.TrimSpace(str[begin:pos])
+			if strings.EqualFold(value, "NULL") {
+				elem = nil
+			} else {
+				elem = value
+			}
+		}
+		if nil != err {
+			return nil, pos, err
+		}
+		elems = append(elems, elem)
+		if pos >= len(str) {
+			break
+		}
+		if str[pos] == '}' {
+			return elems, pos + 1, nil
+		}
+		if str[pos] != ',' {
+			return nil, pos, ErrPGArray
+		}
+		pos++
+	}
+	return nil, pos, ErrPGArray
+}
+
+//解析pos处双引号包围的字符串，反斜杠转义下一个字符，返回结束引号之后的位置
+func parseQuoted(str string, pos int, formatErr error) (string, int, error) {
+	var builder strings.Builder
+	for i := pos + 1; i < len(str); i++ {
+		switch c := str[i]; c {
+		case '\\':
+			i++
+			if i >= len(str) {
+				return "", i, formatErr
+			}
+			builder.WriteByte(str[i])
+		case '"':
+			return builder.String(), i + 1, nil
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	return "", len(str), formatErr
+}
+
+//解析hstore并转换到key为字符串的map，如"a"=>"1", "b"=>NULL
+//NULL值交给handleConvertNull，map[string]string中为空字符串
+func convertPGHstore(str string, target reflect.Value) error {
+	mapType := target.Type()
+	result := reflect.MakeMap(mapType)
+	pos := skipSpaces(str, 0)
+	for pos < len(str) {
+		key, next, err := parseHstoreToken(str, pos)
+		if nil != err || nil == key {
+			return ErrPGHstore
+		}
+		pos = skipSpaces(str, next)
+		if !strings.HasPrefix(str[pos:], "=>") {
+			return ErrPGHstore
+		}
+		value, next, err := parseHstoreToken(str, skipSpaces(str, pos+2))
+		if nil != err {
+			return err
+		}
+		keyValue := reflect.New(mapType.Key()).Elem()
+		if err = valueConvert(*key, keyValue); nil != err {
+			return err
+		}
+		elemValue := reflect.New(mapType.Elem()).Elem()
+		if nil == value {
+			err = handleConvertNull(elemValue)
+		} else {
+			err = valueConvert(*value, elemValue)
+		}
+		if nil != err {
+			return err
+		}
+		result.SetMapIndex(keyValue, elemValue)
+
+		pos = skipSpaces(str, next)
+		if pos < len(str) {
+			if str[pos] != ',' {
+				return ErrPGHstore
+			}
+			pos = skipSpaces(str, pos+1)
+		}
+	}
+	target.Set(result)
+	return nil
+}
+
+//hstore的键或值，未加引号的NULL返回nil
+func parseHstoreToken(str string, pos int) (*string, int, error) {
+	if pos < len(str) && str[pos] == '"' {
+		value, next, err := parseQuoted(str, pos, ErrPGHstore)
+		return &value, next, err
+	}
+	begin := pos
+	for pos < len(str) && str[pos] != ',' && str[pos] != '=' && str[pos] != ' ' {
+		pos++
+	}
+	value := str[begin:pos]
+	if value == "" {
+		return nil, pos, ErrPGHstore
+	}
+	if strings.EqualFold(value, "NULL") {
+		return nil, pos, nil
+	}
+	return &value, pos, nil
+}
+
+func skipSpaces(str string, pos int) int {
+	for pos < len(str) && str[pos] == ' ' {
+		pos++
+	}
+	return pos
+}
+
+//按json解码到字段，字段为指针时分配新的对象
+func convertJSON(src interface{}, target reflect.Value) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return ErrUnSupportTypeConvert
+	}
+	//复用字段中原有的map或结构体时，json.Unmarshal会保留未出现的键，这里总是解码到新值
+	value := reflect.New(target.Type())
+	if err := json.Unmarshal(data, value.Interface()); nil != err {
+		return err
+	}
+	target.Set(value.Elem())
+	return nil
+}
@@ -0,0 +1,102 @@
+package db_scan
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+)
+
+var (
+	ErrGetTarget    = errors.New("Get的目标不能为切片或按两列扫描的map，多行结果使用Select")
+	ErrSelectTarget = errors.New("Select的目标需为切片或按两列扫描的map，单行结果使用Get")
+)
+
+type (
+	//执行查询的对象，*sql.DB、*sql.Tx、*sql.Conn均满足该接口
+	Querier interface {
+		QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	}
+
+	//结果集迭代中的错误，*sql.Rows实现了该接口
+	errRows interface {
+		Err() error
+	}
+
+	//没有数据时返回的错误，同时满足errors.Is(err, sql.ErrNoRows)
+	emptyResultError struct{}
+)
+
+func (emptyResultError) Error() string {
+	return "结果为空"
+}
+
+func (emptyResultError) Is(target error) bool {
+	return target == sql.ErrNoRows
+}
+
+//使用默认扫描器查询单行到dest，没有数据时返回ErrEmptyResult
+func Get(ctx context.Context, q Querier, dest interface{}, query string, args ...interface{}) error {
+	return defaultScanner.Get(ctx, q, dest, query, args...)
+}
+
+//使用默认扫描器查询多行到切片dest，没有数据时不修改dest并返回nil
+func Select(ctx context.Context, q Querier, dest interface{}, query string, args ...interface{}) error {
+	return defaultScanner.Select(ctx, q, dest, query, args...)
+}
+
+//查询并扫描第一行到dest，没有数据时返回ErrEmptyResult，结果集总是会被关闭
+//dest为多行的目标（[]byte以外的切片、按两列扫描的map）时返回ErrGetTarget
+func (s *Scanner) Get(ctx context.Context, q Querier, dest interface{}, query string, args ...interface{}) error {
+	if isMultiRowTarget(dest) {
+		return ErrGetTarget
+	}
+	return s.query(ctx, q, dest, query, args)
+}
+
+//查询并扫描所有行到切片dest，结果集总是会被关闭
+//dest不是多行的目标时返回ErrSelectTarget
+func (s *Scanner) Select(ctx context.Context, q Querier, dest interface{}, query string, args ...interface{}) error {
+	if nil == dest || getObjectType(dest).Kind() != reflect.Ptr {
+		return ErrTargetNotSettable
+	}
+	if !isMultiRowTarget(dest) {
+		return ErrSelectTarget
+	}
+	return s.query(ctx, q, dest, query, args)
+}
+
+func (s *Scanner) query(ctx context.Context, q Querier, dest interface{}, query string, args []interface{}) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if nil != err {
+		return err
+	}
+	err = s.Scan(rows, dest)
+	if closeErr := rows.Close(); nil == err || err == ErrEmptyResult && nil != closeErr {
+		err = closeErr
+	}
+	return err
+}
+
+//扫描多行的目标：[]byte以外的切片，或按两列扫描的map
+func isMultiRowTarget(dest interface{}) bool {
+	if nil == dest || getObjectType(dest).Kind() != reflect.Ptr {
+		return false
+	}
+	t := getObjectType(dest).Elem()
+	switch t.Kind() {
+	case reflect.Slice:
+		return !isBytes(t)
+	case reflect.Map:
+		return !isRowMapType(t)
+	}
+	return false
+}
+
+//结果集迭代中的错误，没有实现Err时返回nil
+func rowsErr(rows IRows) error {
+	if r, ok := rows.(errRows); ok {
+		return r.Err()
+	}
+	return nil
+}
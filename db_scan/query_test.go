@@ -0,0 +1,228 @@
+package db_scan
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/tevid/go-tevid-utils/db_scan/dbtest"
+	. "github.com/tevid/gohamcrest"
+	"math/big"
+	"testing"
+)
+
+var errTestIterate = errors.New("iterate failed")
+
+func newTestDB(t *testing.T, results map[string]*dbtest.ResultSet) (*sql.DB, *dbtest.Driver) {
+	d := dbtest.NewDriver()
+	for query, result := range results {
+		d.Register(query, result)
+	}
+	db := d.DB()
+	t.Cleanup(func() {
+		db.Close()
+	})
+	return db, d
+}
+
+func TestGetAndSelect(t *testing.T) {
+	users := &dbtest.ResultSet{
+		Columns: []string{"id", "name"},
+		Rows:    [][]driver.Value{{int64(1), []byte("a")}, {int64(2), []byte("b")}},
+	}
+	empty := &dbtest.ResultSet{Columns: []string{"id", "name"}}
+	broken := &dbtest.ResultSet{
+		Columns: []string{"id", "name"},
+		Rows:    [][]driver.Value{{int64(1), []byte("a")}},
+		Err:     errTestIterate,
+	}
+	noData := &dbtest.ResultSet{Columns: []string{"data"}}
+	db, d := newTestDB(t, map[string]*dbtest.ResultSet{"users": users, "empty": empty, "broken": broken, "no_data": noData})
+	ctx := context.Background()
+
+	var user testUser
+	Assert(t, Get(ctx, db, &user, "users"), NilVal())
+	Assert(t, user, Equal(testUser{ID: 1, Name: "a"}))
+	Assert(t, d.OpenRows(), Equal(0))
+
+	var list []testUser
+	Assert(t, Select(ctx, db, &list, "users"), NilVal())
+	Assert(t, len(list), Equal(2))
+
+	err := Get(ctx, db, &user, "empty")
+	Assert(t, err, Equal(ErrEmptyResult))
+	Assert(t, errors.Is(err, sql.ErrNoRows), Equal(true))
+	Assert(t, d.OpenRows(), Equal(0))
+
+	list = nil
+	Assert(t, Select(ctx, db, &list, "empty"), NilVal())
+	Assert(t, list, NilVal())
+
+	//迭代中的错误不会被当作没有更多数据
+	Assert(t, Select(ctx, db, &list, "broken"), Equal(errTestIterate))
+	Assert(t, ScanEach(dbtest.NewRows([]string{"id"}).SetErr(errTestIterate), func(*testUser) error { return nil }), Equal(errTestIterate))
+	it := NewIterator[testUser](dbtest.NewRows([]string{"id"}).SetErr(errTestIterate))
+	Assert(t, it.Next(), Equal(false))
+	Assert(t, it.Err(), Equal(errTestIterate))
+	var count int
+	Assert(t, Scan(dbtest.NewRows([]string{"id"}).SetErr(errTestIterate), &count), Equal(errTestIterate))
+
+	Assert(t, Get(ctx, db, &user, "missing"), Not(NilVal()))
+
+	//Get只用于单行，Select只用于多行，不执行查询
+	queries := len(d.Queries())
+	Assert(t, Get(ctx, db, &list, "empty"), Equal(ErrGetTarget))
+	Assert(t, Get(ctx, db, &map[int64]string{}, "empty"), Equal(ErrGetTarget))
+	Assert(t, Select(ctx, db, &user, "users"), Equal(ErrSelectTarget))
+	Assert(t, Select(ctx, db, list, "users"), Equal(ErrTargetNotSettable))
+	Assert(t, len(d.Queries()), Equal(queries))
+	var data []byte
+	Assert(t, Get(ctx, db, &data, "no_data"), Equal(ErrEmptyResult))
+	var row Row
+	Assert(t, Get(ctx, db, &row, "users"), NilVal())
+	var names map[int64]string
+	Assert(t, Select(ctx, db, &names, "users"), NilVal())
+	Assert(t, names, Equal(map[int64]string{1: "a", 2: "b"}))
+}
+
+func TestColumnTypes(t *testing.T) {
+	//文本协议下数值列都以[]byte返回
+	orders := &dbtest.ResultSet{
+		Columns: []string{"id", "amount", "qty", "rate"},
+		Types:   []string{"INT8", "NUMERIC", "DECIMAL", "FLOAT8"},
+		Rows:    [][]driver.Value{{[]byte("1"), []byte("12.50"), []byte("3.00"), []byte("0.5")}},
+	}
+	overflow := &dbtest.ResultSet{
+		Columns: []string{"id", "amount", "qty", "rate"},
+		Types:   []string{"INT8", "NUMERIC", "DECIMAL", "FLOAT8"},
+		Rows:    [][]driver.Value{{[]byte("300"), []byte("1"), []byte("2.5"), []byte("1")}},
+	}
+	db, _ := newTestDB(t, map[string]*dbtest.ResultSet{"orders": orders, "overflow": overflow})
+	ctx := context.Background()
+
+	type Order struct {
+		ID     int64    `pg:"id"`
+		Amount *big.Rat `pg:"amount"`
+		Qty    int      `pg:"qty"`
+		Rate   float64  `pg:"rate"`
+	}
+	var order Order
+	Assert(t, Get(ctx, db, &order, "orders"), NilVal())
+	Assert(t, order.Amount.RatString(), Equal("25/2"))
+	Assert(t, order.Qty, Equal(3))
+	Assert(t, order.Rate, Equal(0.5))
+
+	var row map[string]interface{}
+	Assert(t, Get(ctx, db, &row, "orders"), NilVal())
+	Assert(t, row, Equal(map[string]interface{}{"id": int64(1), "amount": "12.50", "qty": "3.00", "rate": 0.5}))
+
+	type Small struct {
+		ID int8 `pg:"id"`
+	}
+	var small Small
+	err := Get(ctx, db, &small, "overflow")
+	Assert(t, errors.Is(err, ErrValueOverflow), Equal(true))
+	var columnErr *ColumnError
+	Assert(t, errors.As(err, &columnErr), Equal(true))
+	Assert(t, columnErr.Column, Equal("id"))
+	Assert(t, columnErr.Field, Equal("ID"))
+
+	err = Get(ctx, db, &order, "overflow")
+	Assert(t, errors.Is(err, ErrPrecisionLoss), Equal(true))
+
+	//big.Rat按单列扫描
+	var rat big.Rat
+	Assert(t, Scan(newTestRows([]string{"amount"}, []interface{}{"12.5"}), &rat), NilVal())
+	Assert(t, rat.RatString(), Equal("25/2"))
+	var ratPtr *big.Rat
+	Assert(t, Scan(newTestRows([]string{"amount"}, []interface{}{[]byte("0.25")}), &ratPtr), NilVal())
+	Assert(t, ratPtr.RatString(), Equal("1/4"))
+
+	//只匹配完整的类型名
+	Assert(t, parseColumnKind("INTERVAL"), Equal(columnUnknown))
+	Assert(t, parseColumnKind("POINT"), Equal(columnUnknown))
+	Assert(t, parseColumnKind("_INT4"), Equal(columnUnknown))
+	Assert(t, parseColumnKind("bigint unsigned"), Equal(columnInteger))
+	Assert(t, parseColumnKind("DECIMAL(10,2)"), Equal(columnDecimal))
+	Assert(t, parseColumnKind("double precision"), Equal(columnFloat))
+}
+
+func TestScanMulti(t *testing.T) {
+	rows := dbtest.NewRows([]string{"count"}, []interface{}{int64(2)}).
+		AddResultSet([]string{"id", "name"}, []interface{}{int64(1), "a"}, []interface{}{int64(2), "b"})
+	var count int
+	var users []testUser
+	Assert(t, ScanMulti(rows, &count, &users), NilVal())
+	Assert(t, count, Equal(2))
+	Assert(t, len(users), Equal(2))
+
+	rows = dbtest.NewRows([]string{"count"}, []interface{}{int64(2)})
+	err := ScanMulti(rows, &count, &users)
+	Assert(t, errors.Is(err, ErrNoResultSet), Equal(true))
+
+	d := dbtest.NewDriver()
+	d.RegisterMulti("CALL stats()",
+		&dbtest.ResultSet{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(5)}}},
+		&dbtest.ResultSet{Columns: []string{"id", "name"}, Rows: [][]driver.Value{{int64(3), []byte("c")}}},
+	)
+	db := d.DB()
+	defer db.Close()
+	users = nil
+	Assert(t, SelectMulti(context.Background(), db, []interface{}{&count, &users}, "CALL stats()"), NilVal())
+	Assert(t, count, Equal(5))
+	Assert(t, users, Equal([]testUser{{ID: 3, Name: "c"}}))
+	Assert(t, d.OpenRows(), Equal(0))
+}
+
+func TestPaginate(t *testing.T) {
+	const query = "SELECT id, name FROM users WHERE id > $1 AND name <> $2 ORDER BY id LIMIT $3"
+	d := dbtest.NewDriver()
+	d.Handle(query, func(args []interface{}) ([]*dbtest.ResultSet, error) {
+		after, limit := args[0].(int64), args[2].(int64)
+		result := &dbtest.ResultSet{Columns: []string{"id", "name"}}
+		for id := after + 1; id <= 7 && int64(len(result.Rows)) < limit; id++ {
+			result.Rows = append(result.Rows, []driver.Value{id, []byte("u")})
+		}
+		return []*dbtest.ResultSet{result}, nil
+	})
+	db := d.DB()
+	defer db.Close()
+
+	type User struct {
+		ID   int64  `pg:"id,cursor"`
+		Name string `pg:"name"`
+	}
+	var sizes []int
+	var ids []int64
+	err := Paginate(context.Background(), db, "SELECT id, name FROM users WHERE id > :id AND name <> :skip ORDER BY id LIMIT :limit",
+		map[string]interface{}{"id": 0, "skip": "x"}, 3, func(page []User) error {
+			sizes = append(sizes, len(page))
+			for _, u := range page {
+				ids = append(ids, u.ID)
+			}
+			return nil
+		})
+	Assert(t, err, NilVal())
+	Assert(t, sizes, Equal([]int{3, 3, 1}))
+	Assert(t, ids, Equal([]int64{1, 2, 3, 4, 5, 6, 7}))
+	Assert(t, d.Queries()[1].Args, Equal([]interface{}{int64(3), "x", int64(3)}))
+
+	pages := 0
+	err = Paginate(context.Background(), db, "SELECT id, name FROM users WHERE id > :id AND name <> :skip ORDER BY id LIMIT :limit",
+		map[string]interface{}{"id": 0, "skip": "x"}, 3, func(page []User) error {
+			pages++
+			return ErrStopScan
+		})
+	Assert(t, err, NilVal())
+	Assert(t, pages, Equal(1))
+
+	err = Paginate(context.Background(), db, query, nil, 3, func(page []testUser) error { return nil })
+	Assert(t, err, Equal(ErrNoCursor))
+
+	queries := len(d.Queries())
+	for _, size := range []int{0, -1} {
+		err = Paginate(context.Background(), db, query, nil, size, func(page []User) error { return nil })
+		Assert(t, err, Equal(ErrPageSize))
+	}
+	Assert(t, len(d.Queries()), Equal(queries))
+}
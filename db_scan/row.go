@@ -0,0 +1,153 @@
+package db_scan
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+//按查询中列的顺序保存的一行，Values与Columns一一对应，NULL为nil
+//数值列的值与扫描到map时相同：文本协议的整数、浮点数转为int64、uint64、float64，小数转为字符串
+type Row struct {
+	Columns []string
+	Values  []interface{}
+}
+
+var rowType = reflect.TypeOf(Row{})
+
+//列的值，没有该列时返回false
+func (r Row) Get(column string) (interface{}, bool) {
+	for i, name := range r.Columns {
+		if name == column {
+			return r.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+//转为列名到值的map，丢失列的顺序
+func (r Row) Map() map[string]interface{} {
+	mp := make(map[string]interface{}, len(r.Columns))
+	for i, name := range r.Columns {
+		mp[name] = r.Values[i]
+	}
+	return mp
+}
+
+//按列的顺序输出JSON对象，值按exportValue转换
+func (r Row) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range r.Columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if nil != err {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(exportValue(r.Values[i]))
+		if nil != err {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+//逐行读取为Row，同一结果集的各行共用Columns
+func EachRow(rows IRows, fn func(Row) error) error {
+	columns, err := rows.Columns()
+	if nil != err {
+		return err
+	}
+	kinds := columnKinds(rows)
+	for rows.Next() {
+		values, err := scanRowValues(rows, kinds, len(columns))
+		if nil != err {
+			return err
+		}
+		if err = fn(Row{Columns: columns, Values: values}); nil != err {
+			if err == ErrStopScan {
+				return nil
+			}
+			return err
+		}
+	}
+	return rowsErr(rows)
+}
+
+//扫描第一行到Row中
+func scanRow(rows IRows, valueObj reflect.Value) error {
+	found := false
+	err := EachRow(rows, func(row Row) error {
+		valueObj.Set(reflect.ValueOf(row))
+		found = true
+		return ErrStopScan
+	})
+	if nil == err && !found {
+		return ErrEmptyResult
+	}
+	return err
+}
+
+//扫描所有行到[]Row中，没有数据时不修改target
+func scanRowSlice(rows IRows, valueObj reflect.Value) error {
+	var valueSliceObj reflect.Value
+	err := EachRow(rows, func(row Row) error {
+		if !valueSliceObj.IsValid() {
+			valueSliceObj = reflect.MakeSlice(valueObj.Type(), 0, 0)
+		}
+		valueSliceObj = reflect.Append(valueSliceObj, reflect.ValueOf(row))
+		return nil
+	})
+	if nil == err && valueSliceObj.IsValid() {
+		valueObj.Set(valueSliceObj)
+	}
+	return err
+}
+
+//导出时值的表示，与扫描到string字段的转换一致：时间按DefaultTimeFormat格式化，[]byte转为字符串
+//不是合法UTF-8的[]byte（如bytea）按base64编码，避免json.Marshal把无效字节替换为U+FFFD
+func exportValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		if !utf8.Valid(v) {
+			return base64.StdEncoding.EncodeToString(v)
+		}
+		return string(v)
+	case time.Time:
+		return v.Format(DefaultTimeFormat)
+	}
+	return value
+}
+
+//值的文本表示，NULL返回null为true
+func exportText(value interface{}) (text string, null bool) {
+	switch v := exportValue(value).(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, false
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), false
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), false
+	case int64:
+		return strconv.FormatInt(v, 10), false
+	case uint64:
+		return strconv.FormatUint(v, 10), false
+	case bool:
+		return strconv.FormatBool(v), false
+	default:
+		return fmt.Sprint(v), false
+	}
+}
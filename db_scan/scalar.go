@@ -0,0 +1,222 @@
+package db_scan
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var ErrColumnCount = errors.New("结果集列数与目标不匹配")
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+//把单列的值转换到target中
+type valueScanner struct {
+	target reflect.Value
+	column string
+	kind   columnKind
+}
+
+//各列的valueScanner，target在每行扫描前设置
+func newValueScanners(rows IRows) ([]valueScanner, error) {
+	columns, err := rows.Columns()
+	if nil != err {
+		return nil, err
+	}
+	kinds := columnKinds(rows)
+	scanners := make([]valueScanner, len(columns))
+	for i, column := range columns {
+		scanners[i] = valueScanner{column: column, kind: kindAt(kinds, i)}
+	}
+	return scanners, nil
+}
+
+//实现sql.Scanner
+func (v *valueScanner) Scan(src interface{}) error {
+	if nil == src {
+		return handleConvertNull(v.target)
+	}
+	//驱动返回的[]byte只在下一次Next前有效
+	if bytes, ok := src.([]byte); ok {
+		src = append([]byte(nil), bytes...)
+	}
+	if err := valueConvert(normalizeValue(src, v.kind), v.target); nil != err {
+		return &ColumnError{Column: v.column, Err: err}
+	}
+	return nil
+}
+
+//按单列扫描的类型：基本类型、[]byte、time.Time、big.Rat、实现了sql.Scanner的结构体及它们的指针
+func isScalarType(t reflect.Type) bool {
+	if isBytes(t) {
+		return true
+	}
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.Struct:
+		return t == timeType || t == ratType || reflect.PtrTo(t).Implements(scannerType)
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Chan, reflect.Func:
+		return false
+	}
+	return true
+}
+
+//map[string]interface{}，按列名保存一行
+func isRowMapType(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String &&
+		t.Elem().Kind() == reflect.Interface && t.Elem().NumMethod() == 0
+}
+
+//检查结果集列数，返回各列的valueScanner
+func checkColumnCount(rows IRows, expect int) ([]valueScanner, error) {
+	scanners, err := newValueScanners(rows)
+	if nil != err {
+		return nil, err
+	}
+	if len(scanners) != expect {
+		return nil, fmt.Errorf("%w: 需要%d列，实际%d列", ErrColumnCount, expect, len(scanners))
+	}
+	return scanners, nil
+}
+
+//扫描第一行的单列到valueObj中
+func scanScalar(rows IRows, valueObj reflect.Value) error {
+	scanners, err := checkColumnCount(rows, 1)
+	if nil != err {
+		return err
+	}
+	if !rows.Next() {
+		return ErrEmptyResult
+	}
+	scanners[0].target = valueObj
+	return rows.Scan(&scanners[0])
+}
+
+//逐行扫描单列到切片中，没有数据时不修改target
+func scanScalarSlice(rows IRows, valueObj reflect.Value) error {
+	scanners, err := checkColumnCount(rows, 1)
+	if nil != err {
+		return err
+	}
+	var valueSliceObj reflect.Value
+	elemType := valueObj.Type().Elem()
+	for rows.Next() {
+		if !valueSliceObj.IsValid() {
+			valueSliceObj = reflect.MakeSlice(valueObj.Type(), 0, 0)
+		}
+		elem := reflect.New(elemType).Elem()
+		scanners[0].target = elem
+		if err := rows.Scan(&scanners[0]); nil != err {
+			return err
+		}
+		valueSliceObj = reflect.Append(valueSliceObj, elem)
+	}
+	if valueSliceObj.IsValid() {
+		valueObj.Set(valueSliceObj)
+	}
+	return nil
+}
+
+//扫描当前行为列名到值的map，数值列按kinds规范值的类型
+func scanRowMap(rows IRows, columns []string, kinds []columnKind, mapType reflect.Type) (reflect.Value, error) {
+	values, err := scanRowValues(rows, kinds, len(columns))
+	if nil != err {
+		return reflect.Value{}, err
+	}
+	mp := reflect.MakeMapWithSize(mapType, len(columns))
+	for i, name := range columns {
+		value := values[i]
+		var mapValue reflect.Value
+		if nil == value {
+			mapValue = reflect.Zero(mapType.Elem())
+		} else {
+			mapValue = reflect.ValueOf(value)
+		}
+		mp.SetMapIndex(reflect.ValueOf(name).Convert(mapType.Key()), mapValue)
+	}
+	return mp, nil
+}
+
+//扫描当前行的各列的值，数值列按kinds规范值的类型
+func scanRowValues(rows IRows, kinds []columnKind, columnNum int) ([]interface{}, error) {
+	values := make([]interface{}, columnNum)
+	dest := make([]interface{}, columnNum)
+	for i := range dest {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); nil != err {
+		return nil, err
+	}
+	for i, value := range values {
+		//驱动返回的[]byte只在下一次Next前有效
+		if bytes, ok := value.([]byte); ok {
+			value = append([]byte(nil), bytes...)
+		}
+		values[i] = normalizeValue(value, kindAt(kinds, i))
+	}
+	return values, nil
+}
+
+//扫描第一行到map[string]interface{}中
+func scanMap(rows IRows, valueObj reflect.Value) error {
+	columns, err := rows.Columns()
+	if nil != err {
+		return err
+	}
+	if !rows.Next() {
+		return ErrEmptyResult
+	}
+	mp, err := scanRowMap(rows, columns, columnKinds(rows), valueObj.Type())
+	if nil == err {
+		valueObj.Set(mp)
+	}
+	return err
+}
+
+//逐行扫描到[]map[string]interface{}中，没有数据时不修改target
+func scanMapSlice(rows IRows, valueObj reflect.Value) error {
+	columns, err := rows.Columns()
+	if nil != err {
+		return err
+	}
+	kinds := columnKinds(rows)
+	var valueSliceObj reflect.Value
+	for rows.Next() {
+		if !valueSliceObj.IsValid() {
+			valueSliceObj = reflect.MakeSlice(valueObj.Type(), 0, 0)
+		}
+		mp, err := scanRowMap(rows, columns, kinds, valueObj.Type().Elem())
+		if nil != err {
+			return err
+		}
+		valueSliceObj = reflect.Append(valueSliceObj, mp)
+	}
+	if valueSliceObj.IsValid() {
+		valueObj.Set(valueSliceObj)
+	}
+	return nil
+}
+
+//两列结果集扫描到map[K]V中，第一列为key，第二列为value
+//target为nil时新建map，否则在原map上追加
+func scanKeyValues(rows IRows, valueObj reflect.Value) error {
+	scanners, err := checkColumnCount(rows, 2)
+	if nil != err {
+		return err
+	}
+	mapType := valueObj.Type()
+	for rows.Next() {
+		key := reflect.New(mapType.Key()).Elem()
+		value := reflect.New(mapType.Elem()).Elem()
+		scanners[0].target, scanners[1].target = key, value
+		if err := rows.Scan(&scanners[0], &scanners[1]); nil != err {
+			return err
+		}
+		if valueObj.IsNil() {
+			valueObj.Set(reflect.MakeMap(mapType))
+		}
+		valueObj.SetMapIndex(key, value)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package db_scan
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var (
+	ErrUnknownColumn = errors.New("结果集中的列没有对应的字段")
+	ErrMissingColumn = errors.New("字段在结果集中没有对应的列")
+)
+
+type (
+	//可配置的扫描器，包级别的Scan、ScanEach、NewIterator使用默认扫描器
+	Scanner struct {
+		tagName         string
+		nameMapper      func(string) string //没有标签的字段的列名映射，nil表示跳过这类字段
+		strict          bool
+		caseInsensitive bool
+		dialect         Dialect  //Named、BulkInsert生成的占位符风格
+		mappings        sync.Map //结构体类型到映射的缓存
+	}
+
+	//扫描器选项
+	ScannerOption func(*Scanner)
+)
+
+//默认扫描器：按pg标签映射，跳过没有标签的字段，忽略多余的列
+var defaultScanner = NewScanner()
+
+func NewScanner(opts ...ScannerOption) *Scanner {
+	s := &Scanner{tagName: DefaultTagName}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+//使用的标签名称，如db、pg、json
+func WithTagName(tagName string) ScannerOption {
+	return func(s *Scanner) {
+		if tagName != "" {
+			s.tagName = tagName
+		}
+	}
+}
+
+//没有标签的字段按mapper转换字段名作为列名，如ToSnakeCase
+func WithNameMapper(mapper func(string) string) ScannerOption {
+	return func(s *Scanner) {
+		s.nameMapper = mapper
+	}
+}
+
+//没有标签的字段按蛇形命名映射列名，如UserID映射为user_id
+func WithSnakeCase() ScannerOption {
+	return WithNameMapper(ToSnakeCase)
+}
+
+//严格模式：结果集中有列没有对应字段，或有字段没有对应列时返回错误
+func WithStrict() ScannerOption {
+	return func(s *Scanner) {
+		s.strict = true
+	}
+}
+
+//列名与字段名不区分大小写匹配
+func WithCaseInsensitive() ScannerOption {
+	return func(s *Scanner) {
+		s.caseInsensitive = true
+	}
+}
+
+//驼峰命名转蛇形命名，连续的大写字母视为一个单词，如HTTPServer转为http_server
+func ToSnakeCase(name string) string {
+	runes := []rune(name)
+	var builder strings.Builder
+	builder.Grow(len(name) + 4)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				builder.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+//数据集扫描，target为指针，指向：
+//结构体或结构体指针，扫描第一行；基本类型等单列的值，扫描第一行的唯一一列；
+//Row，按列的顺序保存第一行；map[string]interface{}，按列名保存第一行；其他map[K]V，两列结果集的每行作为一对key和value；
+//以及上述类型（两列的map除外）的切片，扫描所有行
+func (s *Scanner) Scan(rows IRows, target interface{}) error {
+	if nil == target || getObjectType(target).Kind() != reflect.Ptr || getObjectValue(target).IsNil() {
+		return ErrTargetNotSettable
+	}
+	err := s.scan(rows, getPtrObjectValue(target))
+	//迭代中断时结果集看起来像是没有更多数据，优先返回迭代中的错误
+	if nil == err || err == ErrEmptyResult {
+		if iterErr := rowsErr(rows); nil != iterErr {
+			return iterErr
+		}
+	}
+	return err
+}
+
+func (s *Scanner) scan(rows IRows, valueObj reflect.Value) error {
+	valueType := valueObj.Type()
+	switch {
+	case valueType == rowType:
+		return scanRow(rows, valueObj)
+	case valueType.Kind() == reflect.Slice && valueType.Elem() == rowType:
+		return scanRowSlice(rows, valueObj)
+	case isScalarType(valueType):
+		return scanScalar(rows, valueObj)
+	case isRowMapType(valueType):
+		return scanMap(rows, valueObj)
+	case valueType.Kind() == reflect.Map:
+		return scanKeyValues(rows, valueObj)
+	case valueType.Kind() == reflect.Slice && isScalarType(valueType.Elem()):
+		return scanScalarSlice(rows, valueObj)
+	case valueType.Kind() == reflect.Slice && isRowMapType(valueType.Elem()):
+		return scanMapSlice(rows, valueObj)
+	case valueType.Kind() == reflect.Slice:
+		return s.scanSlice(rows, valueObj)
+	default:
+		return s.scanSingle(rows, valueObj)
+	}
+}
+
+//使用指定扫描器的ScanEach
+func ScanEachWith[T any](s *Scanner, rows IRows, fn func(*T) error) error {
+	err := s.scanRows(rows, reflect.TypeOf((*T)(nil)), func(value reflect.Value) error {
+		return fn(value.Interface().(*T))
+	})
+	if nil == err {
+		err = rowsErr(rows)
+	}
+	return err
+}
+
+//使用指定扫描器的NewIterator
+func NewIteratorWith[T any](s *Scanner, rows IRows) *Iterator[T] {
+	it := &Iterator[T]{rows: rows}
+	it.group, it.err = s.newRowGrouper(rows, reflect.TypeOf((*T)(nil)).Elem())
+	return it
+}
+
+func (s *Scanner) getStructMapping(structType reflect.Type) *structMapping {
+	if cached, ok := s.mappings.Load(structType); ok {
+		return cached.(*structMapping)
+	}
+	m := &structMapping{scanner: s, fields: make(map[string]*fieldMapping)}
+	m.addFields(structType, nil, "", "", 0)
+	s.mappings.Store(structType, m)
+	return m
+}
+
+//字段的列名，没有对应列时返回空
+func (s *Scanner) columnName(field reflect.StructField) (string, map[string]string) {
+	tag, ok := field.Tag.Lookup(s.tagName)
+	if tag == "-" {
+		return "", nil
+	}
+	name, options := parseTag(tag)
+	if !ok || name == "" {
+		if nil == s.nameMapper {
+			return "", options
+		}
+		name = s.nameMapper(field.Name)
+	}
+	return name, options
+}
+
+//列名在映射中的key
+func (s *Scanner) columnKey(name string) string {
+	if s.caseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+//严格模式下检查列与字段是否一一对应
+func (s *Scanner) checkColumns(columns []string, mapping *structMapping) error {
+	if !s.strict {
+		return nil
+	}
+	matched := make(map[*fieldMapping]bool, len(columns))
+	for _, column := range columns {
+		field := mapping.fields[s.columnKey(column)]
+		if nil == field {
+			return fmt.Errorf("%w: %s", ErrUnknownColumn, column)
+		}
+		matched[field] = true
+	}
+	for _, field := range mapping.ordered {
+		if !matched[field] {
+			return fmt.Errorf("%w: %s", ErrMissingColumn, field.name)
+		}
+	}
+	return nil
+}
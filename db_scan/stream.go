@@ -0,0 +1,289 @@
+package db_scan
+
+import (
+	"errors"
+	"reflect"
+)
+
+//回调中返回该错误可提前结束扫描，ScanEach不会把它当作错误返回
+var ErrStopScan = errors.New("停止扫描")
+
+type (
+	//列到结构体字段的映射，每个结果集只计算一次
+	rowMapper struct {
+		mapping  *structMapping
+		scanners []fieldScanner
+		dest     []interface{}
+		roots    []reflect.Value //当前行的主结构体和各一对多关联的子结构体
+		notNull  []bool          //各根结构体在当前行是否有非NULL的列
+	}
+
+	//把单列的值直接转换到结构体字段中
+	fieldScanner struct {
+		mapper *rowMapper
+		field  *fieldMapping //nil表示丢弃该列
+		column string
+		kind   columnKind
+		json   bool   //按json解码
+		format string //时间格式
+	}
+
+	//按行产出结构体，包含一对多关联时把主键相同的连续行合并为一个结构体
+	rowGrouper struct {
+		rows       IRows
+		mapper     *rowMapper
+		structType reflect.Type
+		pending    reflect.Value //已扫描但还未产出的下一行
+		done       bool
+	}
+
+	//逐行扫描的迭代器
+	Iterator[T any] struct {
+		rows  IRows
+		group *rowGrouper
+		value *T
+		err   error
+	}
+)
+
+//逐行扫描结果集到T，不会把整个结果集加载到内存，T需为结构体
+//T包含一对多关联时，主键相同的连续行合并为一个T，查询需按主键排序
+//fn返回ErrStopScan时提前结束并返回nil，返回其他错误时结束并返回该错误
+func ScanEach[T any](rows IRows, fn func(*T) error) error {
+	return ScanEachWith(defaultScanner, rows, fn)
+}
+
+//构建逐行扫描的迭代器，一对多关联的合并规则与ScanEach相同
+func NewIterator[T any](rows IRows) *Iterator[T] {
+	return NewIteratorWith[T](defaultScanner, rows)
+}
+
+//扫描下一行，没有更多数据或出错时返回false
+func (it *Iterator[T]) Next() bool {
+	it.value = nil
+	if nil != it.err {
+		return false
+	}
+	var value reflect.Value
+	if value, it.err = it.group.next(); nil != it.err {
+		return false
+	}
+	if !value.IsValid() {
+		it.err = rowsErr(it.rows)
+		return false
+	}
+	it.value = value.Interface().(*T)
+	return true
+}
+
+//当前行
+func (it *Iterator[T]) Value() *T {
+	return it.value
+}
+
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+func (it *Iterator[T]) Close() error {
+	return it.rows.Close()
+}
+
+//逐行扫描，每行构造一个targetType类型的值交给fn，targetType可以是结构体或多级指向结构体的指针
+func (s *Scanner) scanRows(rows IRows, targetType reflect.Type, fn func(reflect.Value) error) error {
+	group, err := s.newRowGrouper(rows, derefType(targetType))
+	if nil != err {
+		return err
+	}
+	for {
+		value, err := group.next()
+		if nil != err || !value.IsValid() {
+			return err
+		}
+		if err = fn(derefTo(value, targetType)); nil != err {
+			if err == ErrStopScan {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+//把指向结构体的指针包装或解引用为targetType
+func derefTo(ptr reflect.Value, targetType reflect.Type) reflect.Value {
+	if targetType.Kind() != reflect.Ptr {
+		return ptr.Elem()
+	}
+	for ptr.Type() != targetType {
+		wrapper := reflect.New(ptr.Type())
+		wrapper.Elem().Set(ptr)
+		ptr = wrapper
+	}
+	return ptr
+}
+
+func (s *Scanner) newRowGrouper(rows IRows, structType reflect.Type) (*rowGrouper, error) {
+	columns, err := rows.Columns()
+	if nil != err {
+		return nil, err
+	}
+	mapper, err := s.newRowMapper(columns, columnKinds(rows), structType)
+	if nil != err {
+		return nil, err
+	}
+	return &rowGrouper{rows: rows, mapper: mapper, structType: structType}, nil
+}
+
+//下一个结构体指针，没有更多数据时返回无效值
+func (g *rowGrouper) next() (reflect.Value, error) {
+	current := g.pending
+	g.pending = reflect.Value{}
+	if !current.IsValid() {
+		var err error
+		if current, err = g.scan(); nil != err || !current.IsValid() {
+			return current, err
+		}
+	}
+	mapping := g.mapper.mapping
+	if len(mapping.relations) == 0 {
+		return current, nil
+	}
+	key := pkKey(current.Elem(), mapping.pk)
+	for {
+		value, err := g.scan()
+		if nil != err {
+			return reflect.Value{}, err
+		}
+		if !value.IsValid() {
+			return current, nil
+		}
+		if pkKey(value.Elem(), mapping.pk) != key {
+			g.pending = value
+			return current, nil
+		}
+		mapping.merge(current.Elem(), value.Elem())
+	}
+}
+
+func (g *rowGrouper) scan() (reflect.Value, error) {
+	if g.done || !g.rows.Next() {
+		g.done = true
+		return reflect.Value{}, nil
+	}
+	value := reflect.New(g.structType)
+	if err := g.mapper.scan(g.rows, value.Elem()); nil != err {
+		return reflect.Value{}, err
+	}
+	return value, nil
+}
+
+func (s *Scanner) newRowMapper(columns []string, kinds []columnKind, structType reflect.Type) (*rowMapper, error) {
+	if structType.Kind() != reflect.Struct {
+		return nil, ErrTargetNotSettable
+	}
+	mapping := s.getStructMapping(structType)
+	if len(mapping.relations) > 0 && nil == mapping.pk {
+		return nil, ErrNoPrimaryKey
+	}
+	if err := s.checkColumns(columns, mapping); nil != err {
+		return nil, err
+	}
+	m := &rowMapper{
+		mapping:  mapping,
+		scanners: make([]fieldScanner, len(columns)),
+		dest:     make([]interface{}, len(columns)),
+		roots:    make([]reflect.Value, len(mapping.relations)+1),
+		notNull:  make([]bool, len(mapping.relations)+1),
+	}
+	for i, column := range columns {
+		m.scanners[i] = fieldScanner{
+			mapper: m,
+			field:  mapping.fields[s.columnKey(column)],
+			column: column,
+			kind:   kindAt(kinds, i),
+		}
+		if field := m.scanners[i].field; nil != field {
+			_, m.scanners[i].json = field.options[TagOptionJSON]
+			m.scanners[i].format = field.options[TagOptionFormat]
+		}
+		m.dest[i] = &m.scanners[i]
+	}
+	return m, nil
+}
+
+//扫描当前行到结构体中，structValue需可寻址
+//一对多关联的子结构体有非NULL的列时，追加到对应的切片中
+func (m *rowMapper) scan(rows IRows, structValue reflect.Value) error {
+	m.roots[0] = structValue
+	for i, relation := range m.mapping.relations {
+		m.roots[i+1] = reflect.New(relation.structType).Elem()
+	}
+	for i := range m.notNull {
+		m.notNull[i] = false
+	}
+	if err := rows.Scan(m.dest...); nil != err {
+		return err
+	}
+	for i, relation := range m.mapping.relations {
+		if !m.notNull[i+1] {
+			continue
+		}
+		child := m.roots[i+1]
+		if relation.elemType.Kind() == reflect.Ptr {
+			child = child.Addr()
+		}
+		slice := fieldByIndex(structValue, relation.index, true)
+		slice.Set(reflect.Append(slice, child))
+	}
+	return nil
+}
+
+//实现sql.Scanner
+func (f *fieldScanner) Scan(src interface{}) error {
+	if nil == f.field {
+		return nil
+	}
+	root := f.mapper.roots[f.field.root]
+	if nil == src {
+		//NULL不为嵌套的结构体指针分配对象
+		target := fieldByIndex(root, f.field.index, false)
+		if !target.IsValid() {
+			return nil
+		}
+		return handleConvertNull(target)
+	}
+	f.mapper.notNull[f.field.root] = true
+	//驱动返回的[]byte只在下一次Next前有效
+	if bytes, ok := src.([]byte); ok {
+		src = append([]byte(nil), bytes...)
+	}
+	target := fieldByIndex(root, f.field.index, true)
+	var err error
+	if f.json {
+		err = convertJSON(src, target)
+	} else {
+		err = valueConvertFormat(normalizeValue(src, f.kind), target, f.format)
+	}
+	if nil != err {
+		return &ColumnError{Column: f.column, Field: f.field.path, Err: err}
+	}
+	return nil
+}
+
+//扫描第一行到可寻址的结构体中，没有数据时返回false
+//包含一对多关联时，继续合并主键相同的后续行
+func (s *Scanner) scanFirst(rows IRows, structValue reflect.Value) (bool, error) {
+	group, err := s.newRowGrouper(rows, structValue.Type())
+	if nil != err {
+		return false, err
+	}
+	if !rows.Next() {
+		return false, nil
+	}
+	if err = group.mapper.scan(rows, structValue); nil != err {
+		return true, err
+	}
+	group.pending = structValue.Addr()
+	_, err = group.next()
+	return true, err
+}
@@ -0,0 +1,106 @@
+package file_util
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+//新建文件时WriteIntoFile使用的权限
+const DEFAULT_FILE_PERM os.FileMode = 0644
+
+//原子地写入文件：先写入同目录下的临时文件并fsync，再rename覆盖目标文件并fsync目录
+//写入过程中崩溃时目标文件保持原内容，不会出现写了一半的文件
+//目标文件已存在时沿用其权限和属主（属主仅在有权限时保留），否则与os.WriteFile一致使用perm &^ umask
+//目标文件为符号链接时写入链接指向的文件
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return writeAtomic(path, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+//WriteIntoFile覆盖写的原子版本，每个元素写为一行
+func WriteIntoFileAtomic(path string, content []string, perm os.FileMode) error {
+	return writeAtomic(path, perm, func(w io.Writer) error {
+		for _, s := range content {
+			if _, err := fmt.Fprintln(w, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func writeAtomic(path string, perm os.FileMode, write func(w io.Writer) error) (err error) {
+	if resolved, linkErr := filepath.EvalSymlinks(path); linkErr == nil {
+		path = resolved
+	}
+	info, statErr := os.Stat(path)
+	if statErr == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a dir", path)
+		}
+		perm = info.Mode().Perm()
+	}
+
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	//新文件的权限与os.WriteFile一致，受umask影响
+	tmp, err := createTemp(dir, "."+base+".tmp", perm)
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	buf := bufio.NewWriter(tmp)
+	if err = write(buf); err != nil {
+		return err
+	}
+	if err = buf.Flush(); err != nil {
+		return err
+	}
+	if statErr == nil {
+		//沿用原文件的权限，不受umask影响
+		if err = tmp.Chmod(perm); err != nil {
+			return err
+		}
+		if err = chownLike(tmp, info); err != nil {
+			return err
+		}
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpName, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+//在dir中创建名为prefix加随机数的新文件，与os.CreateTemp不同，权限为perm（受umask影响）而不是0600
+func createTemp(dir, prefix string, perm os.FileMode) (*os.File, error) {
+	for i := 0; ; i++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) && i < 100 {
+			continue
+		}
+		return f, err
+	}
+}
@@ -0,0 +1,62 @@
+package file_util
+
+import (
+	. "github.com/tevid/gohamcrest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.txt")
+
+	Assert(t, WriteFileAtomic(path, []byte("v1"), 0600), NilVal())
+	data, _ := os.ReadFile(path)
+	Assert(t, string(data), Equal("v1"))
+	info, _ := os.Stat(path)
+	Assert(t, info.Mode().Perm(), Equal(os.FileMode(0600)))
+
+	//覆盖时沿用原文件的权限
+	Assert(t, os.Chmod(path, 0640), NilVal())
+	Assert(t, WriteFileAtomic(path, []byte("v2"), 0600), NilVal())
+	data, _ = os.ReadFile(path)
+	Assert(t, string(data), Equal("v2"))
+	info, _ = os.Stat(path)
+	Assert(t, info.Mode().Perm(), Equal(os.FileMode(0640)))
+
+	//写入符号链接指向的文件，链接本身保留
+	link := filepath.Join(dir, "link.txt")
+	Assert(t, os.Symlink(path, link), NilVal())
+	Assert(t, WriteFileAtomic(link, []byte("v3"), 0600), NilVal())
+	data, _ = os.ReadFile(path)
+	Assert(t, string(data), Equal("v3"))
+	info, _ = os.Lstat(link)
+	Assert(t, info.Mode()&os.ModeSymlink != 0, Equal(true))
+
+	//没有遗留临时文件
+	entries, _ := os.ReadDir(dir)
+	Assert(t, len(entries), Equal(2))
+
+	Assert(t, WriteFileAtomic(dir, []byte("x"), 0600), Not(NilVal()))
+
+	//新文件的权限受umask影响，与os.WriteFile一致
+	Assert(t, os.WriteFile(filepath.Join(dir, "plain.txt"), []byte("x"), 0666), NilVal())
+	Assert(t, WriteFileAtomic(filepath.Join(dir, "atomic.txt"), []byte("x"), 0666), NilVal())
+	plain, _ := os.Stat(filepath.Join(dir, "plain.txt"))
+	atomic, _ := os.Stat(filepath.Join(dir, "atomic.txt"))
+	Assert(t, atomic.Mode().Perm(), Equal(plain.Mode().Perm()))
+}
+
+func TestWriteIntoFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	Assert(t, WriteIntoFile(path, []string{"a", "b"}, WRITE_OVER), NilVal())
+	Assert(t, WriteIntoFile(path, []string{"c"}, WRITE_APPEND), NilVal())
+	lines, err := ReadLine(path)
+	Assert(t, err, NilVal())
+	Assert(t, lines, Equal([]string{"a", "b", "c"}))
+
+	Assert(t, WriteIntoFile(path, []string{"d"}, WRITE_OVER), NilVal())
+	lines, _ = ReadLine(path)
+	Assert(t, lines, Equal([]string{"d"}))
+}
@@ -0,0 +1,35 @@
+// +build !windows
+
+package file_util
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+//把临时文件的属主改为与原文件一致，没有权限时（非root写其他用户的文件）保留当前属主
+func chownLike(f *os.File, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if stat.Uid == uint32(os.Geteuid()) && stat.Gid == uint32(os.Getegid()) {
+		return nil
+	}
+	err := f.Chown(int(stat.Uid), int(stat.Gid))
+	if errors.Is(err, os.ErrPermission) {
+		return nil
+	}
+	return err
+}
+
+//fsync目录，使rename持久化
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
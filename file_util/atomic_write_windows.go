@@ -0,0 +1,15 @@
+// +build windows
+
+package file_util
+
+import "os"
+
+//windows没有uid/gid属主
+func chownLike(f *os.File, info os.FileInfo) error {
+	return nil
+}
+
+//windows不支持对目录fsync，rename由文件系统保证
+func syncDir(dir string) error {
+	return nil
+}
@@ -1,13 +1,11 @@
 package file_util
 
 import (
-	"bufio"
 	"crypto/md5"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"strings"
 	"time"
 )
 
@@ -46,34 +44,20 @@ func IsExist(path string) bool {
 	return os.IsExist(err)
 }
 
-//逐行读
+//逐行读，去掉首尾空白，跳过空行和以#开头的注释行，不限制行的长度
 func ReadLine(fileName string) ([]string, error) {
 	if err := CheckDataFileExist(fileName); err != nil {
 		return []string{}, err
 	}
-
-	f, err := os.Open(fileName)
+	result := []string{}
+	err := EachLine(fileName, func(_ int, line string) error {
+		result = append(result, line)
+		return nil
+	}, WithTrimSpace(), WithSkipBlank(), WithCommentPrefixes("#"), WithMaxLineSize(0))
 	if err != nil {
 		return nil, err
 	}
-
-	result := []string{}
-
-	buf := bufio.NewReader(f)
-	for {
-		line, err := buf.ReadString('\n')
-		line = strings.TrimSpace(line)
-		if err != nil {
-			if err == io.EOF {
-				return result, nil
-			}
-			return nil, err
-		}
-		if line != "" && !strings.HasPrefix(line, "#") {
-			result = append(result, line)
-		}
-	}
-	//return result, nil
+	return result, nil
 }
 
 //去重
@@ -128,30 +112,13 @@ func openToAppend(fpath string) (*os.File, error) {
 	return f, nil
 }
 
-//以覆盖的方式打开
-func openToOverwrite(fpath string) (*os.File, error) {
-	f, err := os.OpenFile(fpath, os.O_RDWR|os.O_TRUNC, 0777)
-	if err != nil {
-		f, err = os.Create(fpath)
-		if err != nil {
-			return f, err
-		}
-	}
-	return f, nil
-}
-
-//写入文件
+//写入文件，每个元素写为一行；WRITE_OVER时原子地覆盖，见WriteFileAtomic
 func WriteIntoFile(filepath string, content []string, writeMode int) error {
-
-	var f *os.File
-	var err error
-
-	if writeMode == WRITE_APPEND {
-		f, err = openToAppend(filepath)
-	} else {
-		f, err = openToOverwrite(filepath)
+	if writeMode != WRITE_APPEND {
+		return WriteIntoFileAtomic(filepath, content, DEFAULT_FILE_PERM)
 	}
 
+	f, err := openToAppend(filepath)
 	if err != nil {
 		return err
 	}
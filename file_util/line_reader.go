@@ -0,0 +1,233 @@
+package file_util
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	DEFAULT_MAX_LINE_SIZE = 1 << 20 //默认的单行最大长度
+	READ_BUFFER_SIZE      = 64 << 10
+)
+
+var (
+	ErrLineTooLong = errors.New("行长度超过限制")
+	//回调中返回该错误可提前结束读取，EachLine不会把它当作错误返回
+	ErrStopEach = errors.New("停止读取")
+)
+
+type (
+	//逐行读取的选项
+	LineOption func(*lineOptions)
+
+	lineOptions struct {
+		commentPrefixes []string
+		trimSpace       bool
+		skipBlank       bool
+		maxLineSize     int
+		gzip            bool
+	}
+
+	//逐行读取的迭代器，不会把整个文件加载到内存
+	//for it.Next() { it.Line() }，结束后检查Err并Close
+	LineIterator struct {
+		closer io.Closer //打开的文件，由Reader构建时为nil
+		gz     *gzip.Reader
+		reader *bufio.Reader
+		opts   lineOptions
+		lineNo int
+		line   string
+		err    error
+	}
+)
+
+//跳过以指定前缀开头的注释行，判断前会去掉行首空白
+func WithCommentPrefixes(prefixes ...string) LineOption {
+	return func(o *lineOptions) {
+		o.commentPrefixes = append(o.commentPrefixes, prefixes...)
+	}
+}
+
+//去掉行首尾的空白
+func WithTrimSpace() LineOption {
+	return func(o *lineOptions) {
+		o.trimSpace = true
+	}
+}
+
+//跳过空白行
+func WithSkipBlank() LineOption {
+	return func(o *lineOptions) {
+		o.skipBlank = true
+	}
+}
+
+//单行最大长度，不含换行符，超过时返回ErrLineTooLong，默认为DEFAULT_MAX_LINE_SIZE，小于等于0表示不限制
+func WithMaxLineSize(size int) LineOption {
+	return func(o *lineOptions) {
+		o.maxLineSize = size
+	}
+}
+
+//按文件头识别gzip压缩的输入并透明解压，未压缩的输入照常读取
+func WithGzip() LineOption {
+	return func(o *lineOptions) {
+		o.gzip = true
+	}
+}
+
+//逐行读取文件，lineNo为从1开始的行号，被跳过的行也计入行号
+//最后一行没有换行符时同样会读取；fn返回ErrStopEach时提前结束并返回nil
+func EachLine(path string, fn func(lineNo int, line string) error, opts ...LineOption) error {
+	it, err := NewLineIterator(path, opts...)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	for it.Next() {
+		if err = fn(it.LineNo(), it.Line()); err != nil {
+			if err == ErrStopEach {
+				return nil
+			}
+			return err
+		}
+	}
+	return it.Err()
+}
+
+//打开文件并构建逐行读取的迭代器，使用完需Close
+func NewLineIterator(path string, opts ...LineOption) (*LineIterator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	it, err := NewLineReader(f, opts...)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	it.closer = f
+	return it, nil
+}
+
+//从r构建逐行读取的迭代器，Close不会关闭r
+func NewLineReader(r io.Reader, opts ...LineOption) (*LineIterator, error) {
+	it := &LineIterator{opts: lineOptions{maxLineSize: DEFAULT_MAX_LINE_SIZE}}
+	for _, opt := range opts {
+		opt(&it.opts)
+	}
+	it.reader = bufio.NewReaderSize(r, READ_BUFFER_SIZE)
+	if it.opts.gzip {
+		//gzip文件头：0x1f 0x8b
+		magic, err := it.reader.Peek(2)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+			if it.gz, err = gzip.NewReader(it.reader); err != nil {
+				return nil, err
+			}
+			it.reader = bufio.NewReaderSize(it.gz, READ_BUFFER_SIZE)
+		}
+	}
+	return it, nil
+}
+
+//读取下一个未被跳过的行，没有更多行或出错时返回false
+func (it *LineIterator) Next() bool {
+	for it.err == nil {
+		line, err := it.readLine()
+		if err != nil {
+			if err != io.EOF {
+				it.err = err
+			}
+			return false
+		}
+		if it.opts.trimSpace {
+			line = strings.TrimSpace(line)
+		}
+		if it.skip(line) {
+			continue
+		}
+		it.line = line
+		return true
+	}
+	return false
+}
+
+//当前行，不含换行符
+func (it *LineIterator) Line() string {
+	return it.line
+}
+
+//当前行的行号，从1开始
+func (it *LineIterator) LineNo() int {
+	return it.lineNo
+}
+
+func (it *LineIterator) Err() error {
+	return it.err
+}
+
+func (it *LineIterator) Close() error {
+	var err error
+	if it.gz != nil {
+		err = it.gz.Close()
+	}
+	if it.closer != nil {
+		if closeErr := it.closer.Close(); err == nil {
+			err = closeErr
+		}
+		it.closer = nil
+	}
+	return err
+}
+
+//读取一行并去掉换行符，最后一行没有换行符时同样返回，没有更多数据时返回io.EOF
+func (it *LineIterator) readLine() (string, error) {
+	var buf []byte
+	for {
+		fragment, err := it.reader.ReadSlice('\n')
+		if it.opts.maxLineSize > 0 && len(buf)+len(fragment) > it.opts.maxLineSize+2 {
+			it.lineNo++
+			return "", fmt.Errorf("第%d行: %w", it.lineNo, ErrLineTooLong)
+		}
+		buf = append(buf, fragment...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (err != io.EOF || len(buf) == 0) {
+			return "", err
+		}
+		it.lineNo++
+		buf = bytes.TrimSuffix(buf, []byte("\n"))
+		buf = bytes.TrimSuffix(buf, []byte("\r"))
+		if it.opts.maxLineSize > 0 && len(buf) > it.opts.maxLineSize {
+			return "", fmt.Errorf("第%d行: %w", it.lineNo, ErrLineTooLong)
+		}
+		return string(buf), nil
+	}
+}
+
+//是否跳过空白行和注释行
+func (it *LineIterator) skip(line string) bool {
+	if it.opts.skipBlank && strings.TrimSpace(line) == "" {
+		return true
+	}
+	if len(it.opts.commentPrefixes) == 0 {
+		return false
+	}
+	trimmed := strings.TrimLeft(line, " \t")
+	for _, prefix := range it.opts.commentPrefixes {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
+}
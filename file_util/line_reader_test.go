@@ -0,0 +1,111 @@
+package file_util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	. "github.com/tevid/gohamcrest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadLine(t *testing.T) {
+	//最后一行没有换行符
+	path := writeTestFile(t, "data.txt", []byte("# 注释\r\n a \n\n  #b\nc"))
+	lines, err := ReadLine(path)
+	Assert(t, err, NilVal())
+	Assert(t, lines, Equal([]string{"a", "c"}))
+
+	lines, err = ReadLine(filepath.Join(t.TempDir(), "missing.txt"))
+	Assert(t, err, Not(NilVal()))
+	Assert(t, len(lines), Equal(0))
+}
+
+func TestEachLine(t *testing.T) {
+	path := writeTestFile(t, "data.txt", []byte("a\n\n// b\nc\nd\n"))
+	var lineNos []int
+	var lines []string
+	err := EachLine(path, func(lineNo int, line string) error {
+		lineNos = append(lineNos, lineNo)
+		lines = append(lines, line)
+		if line == "c" {
+			return ErrStopEach
+		}
+		return nil
+	}, WithSkipBlank(), WithCommentPrefixes("//", "#"))
+	Assert(t, err, NilVal())
+	Assert(t, lineNos, Equal([]int{1, 4}))
+	Assert(t, lines, Equal([]string{"a", "c"}))
+
+	//不跳过时保留空行和原样的空白
+	lines = nil
+	err = EachLine(path, func(_ int, line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	Assert(t, err, NilVal())
+	Assert(t, lines, Equal([]string{"a", "", "// b", "c", "d"}))
+}
+
+func TestEachLine_MaxLineSize(t *testing.T) {
+	path := writeTestFile(t, "data.txt", []byte("abc\n"+strings.Repeat("x", 100*1024)+"\n"))
+	var lines []string
+	err := EachLine(path, func(_ int, line string) error {
+		lines = append(lines, line)
+		return nil
+	}, WithMaxLineSize(1024))
+	Assert(t, errors.Is(err, ErrLineTooLong), Equal(true))
+	Assert(t, lines, Equal([]string{"abc"}))
+
+	//超过读缓冲的长行可以完整读取
+	it, err := NewLineIterator(path)
+	Assert(t, err, NilVal())
+	defer it.Close()
+	Assert(t, it.Next(), Equal(true))
+	Assert(t, it.Next(), Equal(true))
+	Assert(t, len(it.Line()), Equal(100*1024))
+	Assert(t, it.LineNo(), Equal(2))
+	Assert(t, it.Next(), Equal(false))
+	Assert(t, it.Err(), NilVal())
+
+	//ReadLine不限制行的长度
+	long := strings.Repeat("y", DEFAULT_MAX_LINE_SIZE+10)
+	path = writeTestFile(t, "long.txt", []byte(long+"\nz"))
+	lines, err = ReadLine(path)
+	Assert(t, err, NilVal())
+	Assert(t, len(lines), Equal(2))
+	Assert(t, lines[0] == long, Equal(true))
+}
+
+func TestLineIterator_Gzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("a\nb"))
+	gz.Close()
+	path := writeTestFile(t, "data.txt.gz", buf.Bytes())
+
+	var lines []string
+	err := EachLine(path, func(_ int, line string) error {
+		lines = append(lines, line)
+		return nil
+	}, WithGzip())
+	Assert(t, err, NilVal())
+	Assert(t, lines, Equal([]string{"a", "b"}))
+
+	//未压缩的输入照常读取
+	it, err := NewLineReader(strings.NewReader("plain"), WithGzip())
+	Assert(t, err, NilVal())
+	Assert(t, it.Next(), Equal(true))
+	Assert(t, it.Line(), Equal("plain"))
+	Assert(t, it.Close(), NilVal())
+}
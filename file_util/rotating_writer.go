@@ -0,0 +1,395 @@
+package file_util
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//按时间切割的周期
+const (
+	ROTATE_NONE   = 0
+	ROTATE_HOURLY = 1
+	ROTATE_DAILY  = 2
+)
+
+//压缩后的备份文件扩展名
+const COMPRESS_SUFFIX = ".gz"
+
+var ErrWriterClosed = errors.New("RotatingWriter已关闭")
+
+type (
+	//RotatingWriter的选项
+	RotateOption func(*RotatingWriter)
+
+	//按大小和/或时间切割的日志文件，实现io.WriteCloser，可并发写入
+	//当前文件始终为path，切割时重命名为"名称-时间.扩展名"，如app.log切割为app-20061222.log
+	//清理过期备份和压缩在后台进行，Close会等待其完成
+	RotatingWriter struct {
+		path       string
+		maxSize    int64
+		interval   int
+		layout     string
+		maxBackups int
+		maxAge     time.Duration
+		compress   bool
+		now        func() time.Time
+
+		mu          sync.Mutex
+		file        *os.File
+		size        int64
+		periodStart time.Time //当前文件所属周期的开始时间
+		closed      bool
+
+		millCh chan struct{}
+		millWg sync.WaitGroup
+	}
+)
+
+//文件大小超过size字节时切割
+func WithMaxSize(size int64) RotateOption {
+	return func(w *RotatingWriter) {
+		w.maxSize = size
+	}
+}
+
+//按时间切割，interval为ROTATE_HOURLY或ROTATE_DAILY
+func WithRotateInterval(interval int) RotateOption {
+	return func(w *RotatingWriter) {
+		w.interval = interval
+	}
+}
+
+//备份文件名中的时间格式，默认按切割周期选择，如ROTATE_DAILY为20060102
+func WithTimeLayout(layout string) RotateOption {
+	return func(w *RotatingWriter) {
+		w.layout = layout
+	}
+}
+
+//最多保留的备份数，0表示不限制
+func WithMaxBackups(n int) RotateOption {
+	return func(w *RotatingWriter) {
+		w.maxBackups = n
+	}
+}
+
+//备份最长保留时间，0表示不限制
+func WithMaxAge(age time.Duration) RotateOption {
+	return func(w *RotatingWriter) {
+		w.maxAge = age
+	}
+}
+
+//后台使用gzip压缩切割出的备份
+func WithCompress() RotateOption {
+	return func(w *RotatingWriter) {
+		w.compress = true
+	}
+}
+
+//打开或创建日志文件，目录不存在时创建
+//已有的文件属于更早的周期时，第一次写入前会先切割
+func NewRotatingWriter(path string, opts ...RotateOption) (*RotatingWriter, error) {
+	w := &RotatingWriter{path: path, now: time.Now}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.layout == "" {
+		switch w.interval {
+		case ROTATE_HOURLY:
+			w.layout = "2006010215"
+		case ROTATE_DAILY:
+			w.layout = "20060102"
+		default:
+			w.layout = "20060102150405"
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := w.openFile(); err != nil {
+		return nil, err
+	}
+	if w.maxBackups > 0 || w.maxAge > 0 || w.compress {
+		w.millCh = make(chan struct{}, 1)
+		w.millWg.Add(1)
+		go w.millLoop()
+		w.mill()
+	}
+	return w, nil
+}
+
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+	if w.file == nil {
+		//上次切割时重新打开失败
+		if err := w.openFile(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+//立即切割，如收到SIGHUP时
+func (w *RotatingWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+	return w.rotate()
+}
+
+//关闭当前文件并等待后台的清理和压缩完成
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+	}
+	if w.millCh != nil {
+		close(w.millCh)
+	}
+	w.mu.Unlock()
+	w.millWg.Wait()
+	return err
+}
+
+func (w *RotatingWriter) openFile() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, DEFAULT_FILE_PERM)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	w.periodStart = w.truncate(w.now())
+	if w.size > 0 {
+		w.periodStart = w.truncate(info.ModTime())
+	}
+	return nil
+}
+
+func (w *RotatingWriter) shouldRotate(writeSize int64) bool {
+	if w.size == 0 {
+		//空文件不切割，但要归入当前周期，否则之后写入的内容会按打开时的周期命名
+		w.periodStart = w.truncate(w.now())
+		return false
+	}
+	if w.maxSize > 0 && w.size+writeSize > w.maxSize {
+		return true
+	}
+	return w.interval != ROTATE_NONE && w.truncate(w.now()).After(w.periodStart)
+}
+
+//重命名当前文件并打开新文件，调用方持有锁
+//失败时重新打开当前文件继续写入，下次写入时重试切割；重新打开也失败时w.file为nil，下次写入时再打开
+func (w *RotatingWriter) rotate() error {
+	if w.file != nil {
+		err := w.file.Close()
+		w.file = nil
+		if err != nil {
+			return err
+		}
+	}
+	if w.size > 0 {
+		if err := os.Rename(w.path, w.backupName()); err != nil {
+			w.openFile()
+			return err
+		}
+	}
+	if err := w.openFile(); err != nil {
+		return err
+	}
+	w.mill()
+	return nil
+}
+
+//备份文件名，按时间切割时为当前文件所属周期，否则为切割时间，重名时加序号
+func (w *RotatingWriter) backupName() string {
+	stamp := w.now()
+	if w.interval != ROTATE_NONE {
+		stamp = w.periodStart
+	}
+	prefix, ext := w.nameParts()
+	name := prefix + stamp.Format(w.layout)
+	for i := 1; ; i++ {
+		candidate := name + ext
+		if !IsExist(candidate) && !IsExist(candidate+COMPRESS_SUFFIX) {
+			return candidate
+		}
+		name = fmt.Sprintf("%s%s.%d", prefix, stamp.Format(w.layout), i)
+	}
+}
+
+//备份文件名的前缀（含目录）和扩展名
+func (w *RotatingWriter) nameParts() (string, string) {
+	ext := filepath.Ext(w.path)
+	return strings.TrimSuffix(w.path, ext) + "-", ext
+}
+
+//按切割周期截断时间
+func (w *RotatingWriter) truncate(t time.Time) time.Time {
+	switch w.interval {
+	case ROTATE_HOURLY:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case ROTATE_DAILY:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return t
+}
+
+//通知后台清理和压缩，已有待处理的通知时忽略
+func (w *RotatingWriter) mill() {
+	if w.millCh == nil {
+		return
+	}
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+}
+
+func (w *RotatingWriter) millLoop() {
+	defer w.millWg.Done()
+	for range w.millCh {
+		//后台没有调用方可以返回错误，失败的文件在下次切割时重试
+		w.millRun()
+	}
+}
+
+type backupFile struct {
+	path    string
+	modTime time.Time
+}
+
+func (w *RotatingWriter) millRun() {
+	backups, err := w.listBackups()
+	if err != nil {
+		return
+	}
+	//按修改时间从新到旧，时间相同时按文件名
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].modTime.Equal(backups[j].modTime) {
+			return backups[i].modTime.After(backups[j].modTime)
+		}
+		return backups[i].path > backups[j].path
+	})
+	keep := backups[:0]
+	cutoff := w.now().Add(-w.maxAge)
+	for i, backup := range backups {
+		if (w.maxBackups > 0 && i >= w.maxBackups) || (w.maxAge > 0 && backup.modTime.Before(cutoff)) {
+			os.Remove(backup.path)
+			continue
+		}
+		keep = append(keep, backup)
+	}
+	if !w.compress {
+		return
+	}
+	for _, backup := range keep {
+		if !strings.HasSuffix(backup.path, COMPRESS_SUFFIX) {
+			compressFile(backup.path, backup.modTime)
+		}
+	}
+}
+
+//列出当前文件的所有备份，包括压缩后的
+//只有"前缀+时间[.序号]+扩展名[.gz]"且时间能按layout解析的文件才是备份，
+//避免误删同目录下如app-access.log这类其他文件
+func (w *RotatingWriter) listBackups() ([]backupFile, error) {
+	prefix, ext := w.nameParts()
+	dir := filepath.Dir(w.path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(prefix)
+	var backups []backupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !w.isBackupName(name, base, ext) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{path: filepath.Join(dir, name), modTime: info.ModTime()})
+	}
+	return backups, nil
+}
+
+func (w *RotatingWriter) isBackupName(name, prefix, ext string) bool {
+	name = strings.TrimSuffix(name, COMPRESS_SUFFIX)
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) || len(name) < len(prefix)+len(ext) {
+		return false
+	}
+	stamp := name[len(prefix) : len(name)-len(ext)]
+	if _, err := time.Parse(w.layout, stamp); err == nil {
+		return true
+	}
+	//重名时追加的序号
+	idx := strings.LastIndex(stamp, ".")
+	if idx < 0 {
+		return false
+	}
+	if _, err := strconv.Atoi(stamp[idx+1:]); err != nil {
+		return false
+	}
+	_, err := time.Parse(w.layout, stamp[:idx])
+	return err == nil
+}
+
+//压缩为path.gz后删除原文件，保留原文件的修改时间以便按时间清理
+func compressFile(path string, modTime time.Time) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	target := path + COMPRESS_SUFFIX
+	err = writeAtomic(target, DEFAULT_FILE_PERM, func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		if _, err := io.Copy(gz, src); err != nil {
+			return err
+		}
+		return gz.Close()
+	})
+	if err != nil {
+		return err
+	}
+	if err = os.Chtimes(target, modTime, modTime); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,176 @@
+package file_util
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/tevid/gohamcrest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func withClock(now func() time.Time) RotateOption {
+	return func(w *RotatingWriter) {
+		w.now = now
+	}
+}
+
+func TestRotatingWriter_Size(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotatingWriter(filepath.Join(dir, "app.log"), WithMaxSize(10), WithTimeLayout("x"))
+	Assert(t, err, NilVal())
+	for _, s := range []string{"12345", "67890", "abc", "defghijk"} {
+		_, err = w.Write([]byte(s))
+		Assert(t, err, NilVal())
+	}
+	Assert(t, w.Close(), NilVal())
+	Assert(t, listDir(t, dir), Equal([]string{"app-x.1.log", "app-x.log", "app.log"}))
+	data, _ := os.ReadFile(filepath.Join(dir, "app-x.log"))
+	Assert(t, string(data), Equal("1234567890"))
+	data, _ = os.ReadFile(filepath.Join(dir, "app.log"))
+	Assert(t, string(data), Equal("defghijk"))
+
+	_, err = w.Write([]byte("x"))
+	Assert(t, err, Equal(ErrWriterClosed))
+}
+
+func TestRotatingWriter_Daily(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2020, 1, 1, 23, 0, 0, 0, time.Local)
+	var mu sync.Mutex
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	w, err := NewRotatingWriter(filepath.Join(dir, "app.log"), WithRotateInterval(ROTATE_DAILY),
+		WithMaxBackups(2), WithCompress(), withClock(clock))
+	Assert(t, err, NilVal())
+
+	for day := 0; day < 4; day++ {
+		fmt.Fprintf(w, "day%d\n", day)
+		mu.Lock()
+		now = now.Add(24 * time.Hour)
+		mu.Unlock()
+	}
+	Assert(t, w.Close(), NilVal())
+	//保留最新的两个备份并压缩
+	Assert(t, listDir(t, dir), Equal([]string{"app-20200102.log.gz", "app-20200103.log.gz", "app.log"}))
+
+	var lines []string
+	err = EachLine(filepath.Join(dir, "app-20200103.log.gz"), func(_ int, line string) error {
+		lines = append(lines, line)
+		return nil
+	}, WithGzip())
+	Assert(t, err, NilVal())
+	Assert(t, lines, Equal([]string{"day2"}))
+}
+
+func TestRotatingWriter_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotatingWriter(filepath.Join(dir, "app.log"), WithMaxSize(1024))
+	Assert(t, err, NilVal())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				fmt.Fprintf(w, "%d-%03d\n", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	Assert(t, w.Close(), NilVal())
+
+	total := 0
+	for _, name := range listDir(t, dir) {
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		Assert(t, len(data) <= 1024, Equal(true))
+		total += strings.Count(string(data), "\n")
+	}
+	Assert(t, total, Equal(800))
+}
+
+func TestRotatingWriter_EmptyFileFollowsPeriod(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+	w, err := NewRotatingWriter(filepath.Join(dir, "app.log"), WithRotateInterval(ROTATE_DAILY),
+		withClock(func() time.Time { return now }))
+	Assert(t, err, NilVal())
+
+	//1月1日打开后没有写入，1月2日的内容不应归入1月1日
+	now = now.Add(24 * time.Hour)
+	fmt.Fprintln(w, "jan2-1")
+	fmt.Fprintln(w, "jan2-2")
+	now = now.Add(24 * time.Hour)
+	fmt.Fprintln(w, "jan3")
+	Assert(t, w.Close(), NilVal())
+
+	Assert(t, listDir(t, dir), Equal([]string{"app-20240102.log", "app.log"}))
+	lines, _ := ReadLine(filepath.Join(dir, "app-20240102.log"))
+	Assert(t, lines, Equal([]string{"jan2-1", "jan2-2"}))
+}
+
+func TestRotatingWriter_KeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"app-access.log", "app-error.log", "app-error-20200101.log", "app-20200101.log", "app-20200101.1.log.gz"} {
+		path := filepath.Join(dir, name)
+		Assert(t, os.WriteFile(path, []byte(name), 0644), NilVal())
+		Assert(t, os.Chtimes(path, old, old), NilVal())
+	}
+	w, err := NewRotatingWriter(filepath.Join(dir, "app.log"), WithRotateInterval(ROTATE_DAILY), WithMaxAge(time.Hour))
+	Assert(t, err, NilVal())
+	Assert(t, w.Close(), NilVal())
+	//只清理本writer的备份
+	Assert(t, listDir(t, dir), Equal([]string{"app-access.log", "app-error-20200101.log", "app-error.log", "app.log"}))
+}
+
+func TestRotatingWriter_RotateFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	path := filepath.Join(dir, "app.log")
+	w, err := NewRotatingWriter(path, WithMaxSize(5), WithTimeLayout("x"))
+	Assert(t, err, NilVal())
+	_, err = w.Write([]byte("abc"))
+	Assert(t, err, NilVal())
+
+	//当前文件被删除时重命名失败，重新打开当前文件后可以继续写入
+	Assert(t, os.Remove(path), NilVal())
+	_, err = w.Write([]byte("def"))
+	Assert(t, err, Not(NilVal()))
+	_, err = w.Write([]byte("ghi"))
+	Assert(t, err, NilVal())
+	data, _ := os.ReadFile(path)
+	Assert(t, string(data), Equal("ghi"))
+
+	//目录被删除时重新打开也失败，之后的写入不会因文件已关闭而失败
+	Assert(t, os.RemoveAll(dir), NilVal())
+	_, err = w.Write([]byte("jkl"))
+	Assert(t, err, Not(NilVal()))
+	_, err = w.Write([]byte("jkl"))
+	Assert(t, errors.Is(err, os.ErrClosed), Equal(false))
+	Assert(t, os.MkdirAll(dir, 0755), NilVal())
+	_, err = w.Write([]byte("mno"))
+	Assert(t, err, NilVal())
+	Assert(t, w.Close(), NilVal())
+	data, _ = os.ReadFile(path)
+	Assert(t, string(data), Equal("mno"))
+}
@@ -0,0 +1,5 @@
+module github.com/tevid/go-tevid-utils
+
+go 1.18
+
+require github.com/tevid/gohamcrest v1.1.1